@@ -0,0 +1,136 @@
+package main
+
+/*
+	langextract collects translatable strings for translators
+
+	Usage: langextract [-lang assets/lang] [-locale cs] [-format po|csv] [-o file] [quest.qst|dialogue.yml...]
+
+	Entries of the source language tables are always exported, texts written
+	directly in the given quests and dialogues are added with suggested keys.
+*/
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/zaklaus/rurik-prototype/src/lang"
+)
+
+func main() {
+	langDir := flag.String("lang", "assets/lang", "directory holding the string tables")
+	locale := flag.String("locale", lang.SourceLocale, "locale to fill translations from")
+	format := flag.String("format", "po", "output format, 'po' or 'csv'")
+	outFile := flag.String("o", "", "output file, standard output when empty")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [-lang dir] [-locale name] [-format po|csv] [-o file] [file...]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	write, ok := map[string]func(io.Writer, string, []lang.Entry, lang.Table) error{
+		"po":  lang.WritePO,
+		"csv": lang.WriteCSV,
+	}[*format]
+
+	if !ok {
+		log.Fatalf("Format '%s' is not supported!\n", *format)
+	}
+
+	source := loadTable(*langDir, lang.SourceLocale)
+	texts := loadTable(*langDir, *locale)
+
+	entries := []lang.Entry{}
+	seen := map[string]bool{}
+
+	for _, name := range lang.Tables {
+		t := lang.Table{}
+		prefix := name + "."
+
+		for k, v := range source {
+			if strings.HasPrefix(k, prefix) {
+				t[k] = v
+			}
+		}
+
+		location := filepath.Join(*langDir, lang.SourceLocale, name+".yaml")
+		entries = append(entries, lang.FromTable(t, location)...)
+	}
+
+	for _, e := range entries {
+		seen[e.Key] = true
+	}
+
+	for _, src := range flag.Args() {
+		data, err := ioutil.ReadFile(src)
+
+		if err != nil {
+			log.Fatalf("File '%s' could not be read: %s\n", src, err)
+		}
+
+		name := strings.ToLower(strings.TrimSuffix(filepath.Base(src), filepath.Ext(src)))
+		var found []lang.Entry
+
+		switch filepath.Ext(src) {
+		case ".qst", ".qsl":
+			found = lang.ExtractQuest(name, src, data)
+		case ".yml", ".yaml":
+			found, err = lang.ExtractDialogue(name, src, data)
+
+			if err != nil {
+				log.Fatalln(err)
+			}
+		default:
+			log.Fatalf("File '%s' is neither a quest nor a dialogue!\n", src)
+		}
+
+		for _, e := range found {
+			if seen[e.Key] {
+				continue
+			}
+
+			seen[e.Key] = true
+			entries = append(entries, e)
+		}
+	}
+
+	out := os.Stdout
+
+	if *outFile != "" {
+		f, err := os.Create(*outFile)
+
+		if err != nil {
+			log.Fatalf("Output '%s' could not be created: %s\n", *outFile, err)
+		}
+
+		defer f.Close()
+		out = f
+	}
+
+	if err := write(out, *locale, entries, texts); err != nil {
+		log.Fatalf("Strings could not be written: %s\n", err)
+	}
+}
+
+func loadTable(dir, locale string) lang.Table {
+	t, err := lang.Load(locale, func(name string) []byte {
+		data, err := ioutil.ReadFile(filepath.Join(dir, strings.TrimPrefix(name, "lang/")))
+
+		if err != nil {
+			return nil
+		}
+
+		return data
+	})
+
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	return t
+}
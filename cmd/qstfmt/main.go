@@ -0,0 +1,119 @@
+package main
+
+/*
+	qstfmt formats quest files into their canonical form
+
+	Usage: qstfmt [-w] [-l] [-check] quest.qst|library.qsl...
+
+	Formatted files are printed to the standard output unless -w is given.
+	-check verifies that the files are already canonical, that formatting
+	them again changes nothing and that no text gets lost along the way.
+*/
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+	"unicode"
+
+	"github.com/zaklaus/rurik-prototype/src/qstfmt"
+)
+
+func main() {
+	write := flag.Bool("w", false, "write the result back to the files")
+	list := flag.Bool("l", false, "list files whose formatting differs")
+	check := flag.Bool("check", false, "fail when files are not in canonical form")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [-w] [-l] [-check] file...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	failed := false
+
+	for _, src := range flag.Args() {
+		data, err := ioutil.ReadFile(src)
+
+		if err != nil {
+			log.Fatalf("File '%s' could not be read: %s\n", src, err)
+		}
+
+		res, err := qstfmt.Format(data)
+
+		if err != nil {
+			log.Fatalf("File '%s' could not be parsed: %s\n", src, err)
+		}
+
+		changed := !bytes.Equal(data, res)
+
+		switch {
+		case *check:
+			if msg := verify(data, res); msg != "" {
+				log.Printf("File '%s' %s!\n", src, msg)
+				failed = true
+			}
+
+		case *list:
+			if changed {
+				fmt.Println(src)
+			}
+
+		case *write:
+			if changed {
+				if err := ioutil.WriteFile(src, res, 0644); err != nil {
+					log.Fatalf("File '%s' could not be written: %s\n", src, err)
+				}
+			}
+
+		default:
+			os.Stdout.Write(res)
+		}
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// verify compares a file with its formatted form, an empty result means the file is canonical
+func verify(data, res []byte) string {
+	if strip(data) != strip(res) {
+		return "loses text when formatted"
+	}
+
+	again, err := qstfmt.Format(res)
+
+	if err != nil {
+		return fmt.Sprintf("can't be parsed once formatted: %s", err)
+	}
+
+	if !bytes.Equal(res, again) {
+		return "is formatted differently on a second pass"
+	}
+
+	if !bytes.Equal(data, res) {
+		return "is not formatted"
+	}
+
+	return ""
+}
+
+// strip drops whitespace and case, which are the only things the formatter may change
+func strip(data []byte) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) || r == '\ufeff' {
+			return -1
+		}
+
+		return unicode.ToLower(r)
+	}, string(data))
+}
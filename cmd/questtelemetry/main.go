@@ -0,0 +1,67 @@
+package main
+
+/*
+	questtelemetry combines quest telemetry logs of playtests into a report
+
+	Usage: questtelemetry [-o file] telemetry.jsonl...
+
+	The report shows how many of the started quests were finished, the median
+	time spent on each stage and the points where players left unfinished quests.
+*/
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/zaklaus/rurik-prototype/src/telemetry"
+)
+
+func main() {
+	outFile := flag.String("o", "", "output file, standard output when empty")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [-o file] telemetry.jsonl...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	events := []telemetry.Event{}
+
+	for _, path := range flag.Args() {
+		f, err := os.Open(path)
+
+		if err != nil {
+			log.Fatalf("File '%s' could not be read: %s\n", path, err)
+		}
+
+		res, err := telemetry.Read(f)
+		f.Close()
+
+		if err != nil {
+			log.Fatalf("File '%s' could not be parsed: %s\n", path, err)
+		}
+
+		events = append(events, res...)
+	}
+
+	out := os.Stdout
+
+	if *outFile != "" {
+		f, err := os.Create(*outFile)
+
+		if err != nil {
+			log.Fatalf("Output '%s' could not be created: %s\n", *outFile, err)
+		}
+
+		defer f.Close()
+		out = f
+	}
+
+	telemetry.WriteReport(out, telemetry.Analyze(events))
+}
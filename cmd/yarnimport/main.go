@@ -0,0 +1,66 @@
+package main
+
+/*
+	yarnimport converts Yarn Spinner-like scripts into dialogue files
+
+	Usage: yarnimport [-o assets/texts] script.yarn...
+*/
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/zaklaus/rurik-prototype/src/yarn"
+	"gopkg.in/yaml.v2"
+)
+
+func main() {
+	outDir := flag.String("o", "assets/texts", "output directory for the dialogue files")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [-o dir] script.yarn...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := os.MkdirAll(*outDir, 0755); err != nil {
+		log.Fatalf("Output directory '%s' could not be created: %s\n", *outDir, err)
+	}
+
+	for _, src := range flag.Args() {
+		data, err := ioutil.ReadFile(src)
+
+		if err != nil {
+			log.Fatalf("Script '%s' could not be read: %s\n", src, err)
+		}
+
+		dia, err := yarn.Import(src, data)
+
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		out, err := yaml.Marshal(dia)
+
+		if err != nil {
+			log.Fatalf("Script '%s' could not be encoded: %s\n", src, err)
+		}
+
+		dest := filepath.Join(*outDir, strings.TrimSuffix(filepath.Base(src), filepath.Ext(src))+".yml")
+
+		if err := ioutil.WriteFile(dest, out, 0644); err != nil {
+			log.Fatalf("Dialogue '%s' could not be written: %s\n", dest, err)
+		}
+
+		log.Printf("Script '%s' has been imported as '%s'!", src, dest)
+	}
+}
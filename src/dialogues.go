@@ -3,6 +3,9 @@ package main
 import (
 	"fmt"
 	"log"
+	"strings"
+
+	"github.com/Knetic/govaluate"
 
 	rl "github.com/zaklaus/raylib-go/raylib"
 	"github.com/zaklaus/rurik/src/core"
@@ -13,14 +16,23 @@ import (
 const (
 	// MouseDoublePress default duration of mouse double press
 	MouseDoublePress = 500
+
+	// maxSilentDialogues limits how many silent nodes can be passed at once,
+	// protects us from jump cycles
+	maxSilentDialogues = 256
 )
 
 var dialogues = make(map[string]Dialogue)
 
 type dialogueData struct {
+	name                 string
 	texts                *Dialogue
 	currentText          *Dialogue
+	nodes                map[string]*Dialogue
+	choices              []*Choice
 	selectedChoice       int
+	timeLimit            float32
+	timeLeft             float32
 	extraTick            bool
 	mouseDoublePressTime int32
 }
@@ -29,6 +41,7 @@ var dialogue dialogueData
 
 // Dialogue defines connversation flow
 type Dialogue struct {
+	ID         string    `yaml:"id"`
 	Name       string    `yaml:"name"`
 	AvatarFile string    `yaml:"avatar"`
 	Text       string    `yaml:"text"`
@@ -36,26 +49,57 @@ type Dialogue struct {
 	Event      string    `yaml:"event"`
 	EventArgs  string    `yaml:"eventArgs"`
 	SkipPrompt bool      `yaml:"skipPrompt"`
-	Next       *Dialogue `yaml:"next"`
-	avatar     *rl.Texture2D
+	Silent     bool      `yaml:"silent"`
+
+	// Timeout picks DefaultChoice once it runs out, AutoAdvance continues
+	// the same way without showing the countdown, both in seconds
+	Timeout       float32 `yaml:"timeout"`
+	DefaultChoice int     `yaml:"defaultChoice"`
+	AutoAdvance   float32 `yaml:"autoAdvance"`
+
+	Set    []string    `yaml:"set"`
+	If     string      `yaml:"if"`
+	Else   *Dialogue   `yaml:"else"`
+	Jump   string      `yaml:"jump"`
+	Next   *Dialogue   `yaml:"next"`
+	Nodes  []*Dialogue `yaml:"nodes"`
+	avatar *rl.Texture2D
 }
 
 // Choice is a selection from dialogue branches
 type Choice struct {
 	Text string    `yaml:"text"`
+	If   string    `yaml:"if"`
 	Next *Dialogue `yaml:"next"`
 }
 
+type dialogueEvent struct {
+	name string
+	args []string
+}
+
 // InitText initializes the dialogue's text
 func InitText(t *Dialogue) {
 	if t.AvatarFile != "" {
 		t.avatar = system.GetTexture("gfx/" + t.AvatarFile)
 	}
 
+	if t.ID != "" && dialogue.nodes != nil {
+		dialogue.nodes[t.ID] = t
+	}
+
 	if t.Next != nil {
 		InitText(t.Next)
 	}
 
+	if t.Else != nil {
+		InitText(t.Else)
+	}
+
+	for _, n := range t.Nodes {
+		InitText(n)
+	}
+
 	if t.Choices != nil {
 		for _, ch := range t.Choices {
 			if ch.Next != nil {
@@ -92,10 +136,256 @@ func InitDialogue(name string) {
 	}
 
 	log.Printf("Initializing dialogue '%s' ...\n", name)
+	dialogue.name = name
 	dialogue.texts = GetDialogue(name)
-	dialogue.currentText = dialogue.texts
+	dialogue.nodes = map[string]*Dialogue{}
 	dialogue.extraTick = false
-	InitText(dialogue.currentText)
+	InitText(dialogue.texts)
+
+	evnts := []dialogueEvent{}
+	dialogue.selectText(enterDialogue(dialogue.texts, &evnts))
+
+	if dialogue.currentText == nil {
+		dialogue.texts = nil
+	}
+
+	fireDialogueEvents(evnts)
+}
+
+// enterDialogue resolves jumps, conditions and silent nodes starting at t
+// and returns the first line to be shown, events raised on the way are collected into evnts
+func enterDialogue(t *Dialogue, evnts *[]dialogueEvent) *Dialogue {
+	for steps := 0; t != nil; steps++ {
+		if steps >= maxSilentDialogues {
+			log.Printf("Dialogue has too many silent steps, is there a jump cycle?\n")
+			return nil
+		}
+
+		if t.Jump != "" {
+			node, ok := dialogue.nodes[t.Jump]
+
+			if !ok {
+				log.Printf("Dialogue node '%s' could not be found!\n", t.Jump)
+				return nil
+			}
+
+			t = node
+			continue
+		}
+
+		if t.If != "" && !evalDialogueCondition(t.If) {
+			t = t.Else
+			continue
+		}
+
+		for _, v := range t.Set {
+			applyDialogueAssignment(v)
+		}
+
+		// skipped lines end the dialogue right away, firing their event
+		if t.SkipPrompt {
+			if t.Event != "" {
+				*evnts = append(*evnts, dialogueEvent{
+					name: t.Event,
+					args: []string{t.EventArgs},
+				})
+			}
+
+			return nil
+		}
+
+		if !t.Silent {
+			return t
+		}
+
+		if t.Event != "" {
+			*evnts = append(*evnts, dialogueEvent{
+				name: t.Event,
+				args: core.CompileEventArgs(t.EventArgs),
+			})
+		}
+
+		t = t.Next
+	}
+
+	return nil
+}
+
+func (d *dialogueData) selectText(t *Dialogue) {
+	d.currentText = t
+	d.selectedChoice = 0
+	d.choices = []*Choice{}
+	d.timeLimit = 0
+
+	if t == nil {
+		return
+	}
+
+	for _, ch := range t.Choices {
+		if ch.If == "" || evalDialogueCondition(ch.If) {
+			d.choices = append(d.choices, ch)
+		}
+	}
+
+	if t.Timeout > 0 {
+		d.timeLimit = t.Timeout
+	} else if t.AutoAdvance > 0 {
+		d.timeLimit = t.AutoAdvance
+	}
+
+	d.timeLeft = d.timeLimit
+}
+
+// defaultChoice returns the visible choice index picked when the time runs out
+func (d *dialogueData) defaultChoice() int {
+	t := d.currentText
+
+	if t.DefaultChoice < 0 || t.DefaultChoice >= len(t.Choices) {
+		return d.selectedChoice
+	}
+
+	for idx, ch := range d.choices {
+		if ch == t.Choices[t.DefaultChoice] {
+			return idx
+		}
+	}
+
+	return d.selectedChoice
+}
+
+// updateTimer counts down timed lines, it advances the dialogue once the time is up
+// and reports whether it did so. Time only passes while dialogues are updated, so pausing the game pauses it too
+func (d *dialogueData) updateTimer() bool {
+	if d.timeLimit <= 0 {
+		return false
+	}
+
+	d.timeLeft -= system.FrameTime * float32(core.TimeScale)
+
+	if d.timeLeft > 0 {
+		return false
+	}
+
+	d.selectedChoice = d.defaultChoice()
+	advanceDialogue()
+
+	return true
+}
+
+func fireDialogueEvents(evnts []dialogueEvent) {
+	for _, v := range evnts {
+		core.FireEvent(v.name, v.args)
+	}
+}
+
+// evalDialogueExpr evaluates an expression, variables are written as '[name]'
+// and are looked up in the world state or the running quests
+func evalDialogueExpr(exprStr string) (interface{}, bool) {
+	expr, err := govaluate.NewEvaluableExpression(exprStr)
+
+	if err != nil {
+		log.Printf("Dialogue expression '%s' is invalid: %s\n", exprStr, err)
+		return nil, false
+	}
+
+	params := map[string]interface{}{}
+
+	for _, v := range expr.Vars() {
+		params[v] = getDialogueVariable(v)
+	}
+
+	res, err := expr.Evaluate(params)
+
+	if err != nil {
+		log.Printf("Dialogue expression '%s' could not be evaluated: %s\n", exprStr, err)
+		return nil, false
+	}
+
+	return res, true
+}
+
+func getDialogueVariable(name string) interface{} {
+	if !isWorldFlag(name) {
+		val, _ := currentGameMode.quests.getGlobalVariable(name)
+		return val
+	}
+
+	val, ok := currentGameMode.world.get(name)
+
+	if !ok {
+		return float64(0)
+	}
+
+	switch val.kind {
+	case kindNumber:
+		return val.value.(*questVarNumber).value
+	case kindString:
+		return val.value.(*questVarString).value
+	}
+
+	return val.value.str()
+}
+
+func evalDialogueCondition(cond string) bool {
+	res, ok := evalDialogueExpr(cond)
+
+	if !ok {
+		return false
+	}
+
+	switch val := res.(type) {
+	case float64:
+		return val != 0
+	case bool:
+		return val
+	case string:
+		return val != ""
+	}
+
+	return false
+}
+
+// applyDialogueAssignment handles assignments in form of 'name = expr'
+func applyDialogueAssignment(assign string) {
+	sep := strings.Index(assign, "=")
+
+	if sep == -1 {
+		log.Printf("Dialogue assignment '%s' is invalid!\n", assign)
+		return
+	}
+
+	res, ok := evalDialogueExpr(assign[sep+1:])
+
+	if !ok {
+		return
+	}
+
+	name := strings.TrimSpace(assign[:sep])
+	var num float64
+
+	switch val := res.(type) {
+	case float64:
+		num = val
+	case bool:
+		if val {
+			num = 1
+		}
+	case string:
+		if !isWorldFlag(name) {
+			log.Printf("Dialogue assignment '%s' sets a string, only world flags can hold strings!\n", assign)
+			return
+		}
+
+		currentGameMode.world.setString(name, val)
+		return
+	}
+
+	if isWorldFlag(name) {
+		currentGameMode.world.setNumber(name, num)
+		return
+	}
+
+	currentGameMode.quests.setGlobalVariable(name, num)
 }
 
 func updateDialogue() {
@@ -111,6 +401,10 @@ func updateDialogue() {
 		return
 	}
 
+	if dialogue.updateTimer() {
+		return
+	}
+
 	if !dialogue.extraTick {
 		dialogue.extraTick = system.IsKeyReleased("use")
 		return
@@ -124,19 +418,19 @@ func updateDialogue() {
 		dialogue.mouseDoublePressTime = 0
 	}
 
-	if len(dialogue.currentText.Choices) > 0 {
+	if len(dialogue.choices) > 0 {
 		if system.IsKeyPressed("up") {
 			dialogue.selectedChoice--
 
 			if dialogue.selectedChoice < 0 {
-				dialogue.selectedChoice = len(dialogue.currentText.Choices) - 1
+				dialogue.selectedChoice = len(dialogue.choices) - 1
 			}
 		}
 
 		if system.IsKeyPressed("down") {
 			dialogue.selectedChoice++
 
-			if dialogue.selectedChoice >= len(dialogue.currentText.Choices) {
+			if dialogue.selectedChoice >= len(dialogue.choices) {
 				dialogue.selectedChoice = 0
 			}
 		}
@@ -147,34 +441,40 @@ func updateDialogue() {
 			dialogue.mouseDoublePressTime = 0
 		}
 
-		evnt := dialogue.currentText.Event
-		evntArglist := dialogue.currentText.EventArgs
-		evntArgs := core.CompileEventArgs(evntArglist)
+		advanceDialogue()
+	}
+}
 
-		if len(dialogue.currentText.Choices) > 0 {
-			dialogue.currentText = dialogue.currentText.Choices[dialogue.selectedChoice].Next
-		} else {
-			dialogue.currentText = dialogue.currentText.Next
-		}
+// advanceDialogue leaves the current line through the selected choice
+func advanceDialogue() {
+	evnts := []dialogueEvent{}
 
-		if dialogue.currentText != nil && dialogue.currentText.SkipPrompt {
-			evnt = dialogue.currentText.Event
-			evntArglist = dialogue.currentText.EventArgs
-			evntArgs = []string{evntArglist}
+	if dialogue.currentText.Event != "" {
+		evnts = append(evnts, dialogueEvent{
+			name: dialogue.currentText.Event,
+			args: core.CompileEventArgs(dialogue.currentText.EventArgs),
+		})
+	}
 
-			dialogue.currentText = nil
-		}
+	var next *Dialogue
 
-		if dialogue.currentText == nil {
-			dialogue.texts = nil
-			dialogue.extraTick = true
-			core.CanSave = core.BitsClear(core.CanSave, core.IsInDialogue)
-		}
+	if len(dialogue.choices) > 0 {
+		choice := dialogue.choices[dialogue.selectedChoice]
+		logDialogueChoice(dialogue.name, dialogue.currentText, choice)
+		next = choice.Next
+	} else {
+		next = dialogue.currentText.Next
+	}
 
-		if evnt != "" {
-			core.FireEvent(evnt, evntArgs)
-		}
+	dialogue.selectText(enterDialogue(next, &evnts))
+
+	if dialogue.currentText == nil {
+		dialogue.texts = nil
+		dialogue.extraTick = true
+		core.CanSave = core.BitsClear(core.CanSave, core.IsInDialogue)
 	}
+
+	fireDialogueEvents(evnts)
 }
 
 func drawDialogue() {
@@ -187,6 +487,12 @@ func drawDialogue() {
 	start := system.ScreenHeight - height
 
 	rl.DrawRectangle(0, start, width, height, rl.NewColor(46, 46, 84, 255))
+
+	// countdown of timed choices
+	if dialogue.currentText.Timeout > 0 && dialogue.timeLimit > 0 {
+		left := clamp(dialogue.timeLeft/dialogue.timeLimit, 0, 1)
+		rl.DrawRectangle(0, start, int32(float32(system.ScreenWidth)*left), 3, rl.Orange)
+	}
 	rl.DrawRectangle(5, start+5, 32, 32, rl.NewColor(53, 64, 59, 255))
 	rl.DrawRectangleLines(4, start+4, 34, 34, rl.NewColor(55, 148, 110, 255))
 
@@ -206,7 +512,7 @@ func drawDialogue() {
 	}
 
 	rl.DrawText(
-		ot.Name,
+		localizeText(ot.Name),
 		45,
 		start+16,
 		10,
@@ -214,7 +520,7 @@ func drawDialogue() {
 	)
 
 	rl.DrawText(
-		ot.Text,
+		localizeText(ot.Text),
 		5,
 		start+45,
 		10,
@@ -225,15 +531,15 @@ func drawDialogue() {
 	chsX := system.ScreenWidth - 220
 	chsY := start + 16
 
-	if len(ot.Choices) > 0 {
-		for idx, ch := range ot.Choices {
+	if len(dialogue.choices) > 0 {
+		for idx, ch := range dialogue.choices {
 			ypos := chsY + int32(idx)*15 - 2
 			if idx == dialogue.selectedChoice {
 				rl.DrawRectangle(chsX, ypos, 200, 15, rl.DarkPurple)
 			}
 
 			rl.DrawText(
-				fmt.Sprintf("%d. %s", idx+1, ch.Text),
+				fmt.Sprintf("%d. %s", idx+1, localizeText(ch.Text)),
 				chsX+5,
 				chsY+int32(idx)*15,
 				10,
@@ -257,7 +563,7 @@ func drawDialogue() {
 	} else {
 		rl.DrawRectangle(chsX, chsY-2, 200, 15, rl.DarkPurple)
 		rl.DrawText(
-			"Press E to continue...",
+			tr("ui.dialogue.continue"),
 			chsX+5,
 			chsY,
 			10,
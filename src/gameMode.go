@@ -2,9 +2,8 @@ package main
 
 import (
 	"encoding/gob"
+	"log"
 	"math"
-	"math/rand"
-	"time"
 
 	rl "github.com/zaklaus/raylib-go/raylib"
 	"github.com/zaklaus/rurik/src/core"
@@ -16,7 +15,11 @@ type gameMode struct {
 	textWave       int32
 	showHelpScreen bool
 	quests         questManager
+	world          worldState
 	pda            pdaSystem
+
+	// name of the map being played
+	mapName string
 }
 
 const (
@@ -28,12 +31,15 @@ const (
 )
 
 func (g *gameMode) Init() {
-	rand.Seed(int64(time.Now().Nanosecond()))
+	seedRandomness(gameSeed)
+	initLocale(gameLocale)
 	initLevels()
 	initHUD()
 
 	g.playState = stateLevelSelection
 	g.quests = makeQuestManager()
+	g.quests.seed = gameSeed
+	g.world = makeWorldState()
 	g.pda = makePDA()
 }
 
@@ -59,10 +65,11 @@ func (g *gameMode) Update() {
 		if system.IsKeyPressed("use") {
 			g.playState = stateLevelSelection
 			g.quests.quests = []quest{}
+			g.world.reset()
 		}
 
 		if rl.IsKeyPressed(rl.KeyEscape) {
-			core.CloseGame()
+			quitGame()
 			return
 		}
 
@@ -85,6 +92,7 @@ func (g *gameMode) Update() {
 		updateHUD()
 		updateDialogue()
 		updateNotifications()
+		g.quests.deliverEvents()
 		g.quests.processQuests()
 
 		/* particle systems */
@@ -98,6 +106,10 @@ func (g *gameMode) Update() {
 			quitGame()
 		}
 
+		if core.DebugMode {
+			updateQuestProfile()
+		}
+
 		if rl.IsKeyPressed(rl.KeyF5) {
 			core.FlushMaps()
 			g.playLevelSelection()
@@ -111,27 +123,39 @@ func (g *gameMode) Update() {
 
 func (g *gameMode) Serialize(enc *gob.Encoder) {
 	data := gameSaveData{
-		globID: globalIDCounter,
-		quests: g.quests,
-		pda:    g.pda,
+		GlobID: globalIDCounter,
+		Quests: g.quests.save(),
+		World:  g.world,
+	}
+
+	if err := enc.Encode(data); err != nil {
+		log.Printf("Game mode could not be saved: %s\n", err)
 	}
 
-	enc.Encode(data)
+	if questProgressFile != "" {
+		saveQuestProgress(questProgressFile, data.Quests)
+	}
 }
 
 func (g *gameMode) Deserialize(dec *gob.Decoder) {
 	var saveData gameSaveData
-	dec.Decode(&saveData)
 
-	globalIDCounter = saveData.globID
-	g.quests = saveData.quests
-	g.pda = saveData.pda
+	if err := dec.Decode(&saveData); err != nil {
+		log.Printf("Game mode could not be loaded: %s\n", err)
+		return
+	}
+
+	globalIDCounter = saveData.GlobID
+	g.quests.restore(saveData.Quests)
+	g.world = saveData.World
 }
 
+// gameSaveData is the state of the game mode kept in the save,
+// the PDA only holds the UI so it's built anew
 type gameSaveData struct {
-	globID int64
-	quests questManager
-	pda    pdaSystem
+	GlobID int64
+	Quests questManagerSave
+	World  worldState
 }
 
 func (g *gameMode) Draw() {
@@ -154,12 +178,12 @@ func (g *gameMode) DrawUI() {
 	switch g.playState {
 	case stateTitleScreen:
 		core.DrawTextCentered("Darkorbia", system.ScreenWidth/2, system.ScreenHeight/2-20+g.textWave, 24, rl.RayWhite)
-		core.DrawTextCentered("Press E/ENTER to continue", system.ScreenWidth/2, system.ScreenHeight/2+5+g.textWave, 14, rl.White)
+		core.DrawTextCentered(tr("ui.title.continue"), system.ScreenWidth/2, system.ScreenHeight/2+5+g.textWave, 14, rl.White)
 
 	case statePaused:
 		rl.DrawRectangle(0, 0, system.ScreenWidth, system.ScreenHeight, rl.Fade(rl.Black, 0.8))
 		core.DrawTextCentered("Darkorbia", system.ScreenWidth/2, system.ScreenHeight/2-20+g.textWave, 24, rl.RayWhite)
-		core.DrawTextCentered("Press ESC to unpause or E/ENTER to return to the menu", system.ScreenWidth/2, system.ScreenHeight/2+5+g.textWave, 14, rl.White)
+		core.DrawTextCentered(tr("ui.pause.hint"), system.ScreenWidth/2, system.ScreenHeight/2+5+g.textWave, 14, rl.White)
 
 	case stateLevelSelection:
 		core.DrawTextCentered("Darkorbia", system.ScreenWidth/2, system.ScreenHeight/2-20+g.textWave, 24, rl.RayWhite)
@@ -182,6 +206,9 @@ func (g *gameMode) DebugDraw() {
 			core.CurrentMap.World.DrawDebugObjects()
 		}
 		rl.EndMode2D()
+
+		drawQuestDiagnostics()
+		drawQuestProfile()
 	}
 }
 
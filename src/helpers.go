@@ -1,10 +1,12 @@
 package main
 
 import (
+	"bytes"
+	"encoding/gob"
 	"math"
-	"strconv"
 
 	"github.com/zaklaus/rurik/src/core"
+	"github.com/zaklaus/rurik/src/system"
 )
 
 func roundFloat(x float32) float32 {
@@ -37,7 +39,25 @@ func clamp(x, a, b float32) float32 {
 	return x
 }
 
-func atoiUnsafe(s string) int {
-	val, _ := strconv.Atoi(s)
-	return val
+// readAsset returns the contents of an asset, nil when it's missing.
+// Tests replace it to serve quests from memory
+var readAsset = func(name string) []byte {
+	asset := system.FindAsset(name)
+
+	if asset == nil {
+		return nil
+	}
+
+	return asset.Data
+}
+
+// gobEncode encodes a single value, used by types with unexported fields to save them
+func gobEncode(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(v)
+	return buf.Bytes(), err
+}
+
+func gobDecode(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
 }
@@ -1,10 +1,12 @@
 package main
 
 import (
+	"fmt"
 	"log"
-	"math/rand"
+	"math"
 
 	rl "github.com/zaklaus/raylib-go/raylib"
+	"github.com/zaklaus/raylib-go/raymath"
 	"github.com/zaklaus/rurik/src/core"
 	"github.com/zaklaus/rurik/src/system"
 )
@@ -24,6 +26,9 @@ const (
 
 	valueUpdatePauseTime = 0.3
 	valueUpdateTime      = 0.4
+
+	hudWaypointSize   = 8
+	hudWaypointMargin = 16
 )
 
 type barStat struct {
@@ -165,6 +170,93 @@ func drawHUD() {
 			rl.White,
 		)
 	}
+
+	drawObjectives()
+}
+
+// drawObjectives shows the tracked quest's current objective under the HUD frame
+func drawObjectives() {
+	qs := currentGameMode.quests.getTrackedQuest()
+
+	if qs == nil {
+		return
+	}
+
+	x := int32(frameDestPosX)
+	y := int32(frameDestPosY + frameHeight*frameScaling + 5)
+
+	rl.DrawText(qs.processText(qs.title), x, y, 10, rl.Orange)
+
+	sta, ok := qs.getCurrentStage()
+
+	if !ok {
+		return
+	}
+
+	y += 12
+	rl.DrawText(fmt.Sprintf("%s %s", sta.marker(), sta.text(qs)), x, y, 10, rl.RayWhite)
+
+	if tm, ok := qs.timers[sta.timer]; ok && tm.time > 0 {
+		y += 12
+		secs := int32(math.Ceil(float64(tm.time)))
+		rl.DrawText(fmt.Sprintf("%d:%02d", secs/60, secs%60), x, y, 10, rl.Gold)
+	}
+
+	if pos, ok := qs.getWaypoint(sta); ok {
+		drawWaypoint(pos)
+	}
+}
+
+// drawWaypoint points at a world position, the marker sticks to the screen's edge when the position is off-screen
+func drawWaypoint(pos rl.Vector2) {
+	cam := core.RenderCamera
+	zoom := cam.Zoom
+
+	if zoom == 0 {
+		zoom = 1
+	}
+
+	screenPos := rl.NewVector2(
+		(pos.X-cam.Target.X)*zoom+cam.Offset.X,
+		(pos.Y-cam.Target.Y)*zoom+cam.Offset.Y,
+	)
+
+	center := rl.NewVector2(float32(system.ScreenWidth)/2, float32(system.ScreenHeight)/2)
+	dir := raymath.Vector2Subtract(screenPos, center)
+
+	if raymath.Vector2Length(dir) < 1 {
+		dir = rl.NewVector2(0, 1)
+	}
+
+	halfW := center.X - hudWaypointMargin
+	halfH := center.Y - hudWaypointMargin
+	scale := float32(math.Min(
+		float64(halfW/float32(math.Abs(float64(dir.X))+0.001)),
+		float64(halfH/float32(math.Abs(float64(dir.Y))+0.001)),
+	))
+
+	onScreen := scale >= 1
+	normal := dir
+	raymath.Vector2Normalize(&normal)
+
+	if onScreen {
+		// point down at the target from above
+		normal = rl.NewVector2(0, 1)
+		screenPos.Y -= hudWaypointSize * 2
+	} else {
+		screenPos = rl.NewVector2(center.X+dir.X*scale, center.Y+dir.Y*scale)
+	}
+
+	tip := rl.NewVector2(screenPos.X+normal.X*hudWaypointSize, screenPos.Y+normal.Y*hudWaypointSize)
+	base := rl.NewVector2(screenPos.X-normal.X*hudWaypointSize/2, screenPos.Y-normal.Y*hudWaypointSize/2)
+	side := rl.NewVector2(normal.Y*hudWaypointSize*0.75, -normal.X*hudWaypointSize*0.75)
+
+	rl.DrawTriangle(
+		tip,
+		rl.NewVector2(base.X+side.X, base.Y+side.Y),
+		rl.NewVector2(base.X-side.X, base.Y-side.Y),
+		rl.Gold,
+	)
 }
 
 func applyBarStatValueChange(stat int, value float32) {
@@ -183,7 +275,7 @@ func updateHUD() {
 	{
 		if rl.IsKeyPressed(rl.KeyF8) {
 			for k := range barStats {
-				applyBarStatValueChange(k, (rand.Float32()*2-1)*10)
+				applyBarStatValueChange(k, (simulationRand.Float32()*2-1)*10)
 			}
 		}
 	}
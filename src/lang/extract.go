@@ -0,0 +1,249 @@
+package lang
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/zaklaus/rurik-prototype/src/yarn"
+	"gopkg.in/yaml.v2"
+)
+
+// Entry is a translatable string
+type Entry struct {
+	Key      string
+	Text     string
+	Location string
+}
+
+// questTextResources lists QRC resources holding player-facing text
+var questTextResources = map[string]bool{
+	"message": true,
+	"stage":   true,
+}
+
+// FromTable lists entries of a string table
+func FromTable(t Table, location string) []Entry {
+	res := []Entry{}
+
+	for _, k := range t.Keys() {
+		res = append(res, Entry{
+			Key:      k,
+			Text:     t[k],
+			Location: location,
+		})
+	}
+
+	return res
+}
+
+// ExtractQuest collects texts written directly in a quest file, texts
+// already referring to a key are skipped, keys are suggested as 'quests.<name>.<title|briefing|resource>'
+func ExtractQuest(name, file string, data []byte) []Entry {
+	res := []Entry{}
+	lines := strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+
+	add := func(id string, line int, text string) {
+		text = strings.TrimSpace(text)
+
+		if text == "" || IsKey(text) {
+			return
+		}
+
+		res = append(res, Entry{
+			Key:      fmt.Sprintf("quests.%s.%s", name, id),
+			Text:     text,
+			Location: fmt.Sprintf("%s:%d", file, line+1),
+		})
+	}
+
+	// block collects a text block which ends with an empty line
+	block := func(first string, idx int) (string, int) {
+		text := []string{}
+
+		if first = strings.TrimSpace(first); first != "" {
+			text = append(text, first)
+		}
+
+		for idx+1 < len(lines) && strings.TrimSpace(lines[idx+1]) != "" {
+			idx++
+			text = append(text, strings.TrimSpace(lines[idx]))
+		}
+
+		return strings.Join(text, "\n"), idx
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		sep := strings.Index(line, ":")
+
+		if sep == -1 {
+			continue
+		}
+
+		kw := strings.ToLower(strings.TrimSpace(line[:sep]))
+		rest := line[sep+1:]
+
+		switch {
+		case kw == "qst":
+			return res
+
+		case kw == "title":
+			add("title", i, rest)
+
+		case kw == "briefing":
+			start := i
+			text, end := block(rest, i)
+			add("briefing", start, text)
+			i = end
+
+		case questTextResources[kw]:
+			fields := strings.Fields(rest)
+
+			if len(fields) == 0 {
+				continue
+			}
+
+			start := i
+			text, end := block("", i)
+			add(fields[0], start, text)
+			i = end
+		}
+	}
+
+	return res
+}
+
+// ExtractDialogue collects texts written directly in a dialogue file, keys are suggested
+// as 'dialogues.<name>.<node>' where node is the node ID or its order in the file
+func ExtractDialogue(name, file string, data []byte) ([]Entry, error) {
+	var root yarn.Dialogue
+
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("%s: %s", file, err)
+	}
+
+	res := []Entry{}
+	counter := 0
+
+	add := func(key, text string) {
+		if text == "" || IsKey(text) {
+			return
+		}
+
+		res = append(res, Entry{
+			Key:      key,
+			Text:     text,
+			Location: file,
+		})
+	}
+
+	var walk func(d *yarn.Dialogue)
+	walk = func(d *yarn.Dialogue) {
+		if d == nil {
+			return
+		}
+
+		counter++
+		key := fmt.Sprintf("dialogues.%s.%d", name, counter)
+
+		if d.ID != "" {
+			key = fmt.Sprintf("dialogues.%s.%s", name, d.ID)
+		}
+
+		add(key+".name", d.Name)
+		add(key+".text", d.Text)
+
+		for idx, ch := range d.Choices {
+			add(fmt.Sprintf("%s.choice%d", key, idx+1), ch.Text)
+		}
+
+		walk(d.Next)
+		walk(d.Else)
+
+		for _, ch := range d.Choices {
+			walk(ch.Next)
+		}
+
+		for _, n := range d.Nodes {
+			walk(n)
+		}
+	}
+
+	walk(&root)
+	return res, nil
+}
+
+// WritePO writes entries as a gettext catalog, keys are stored as message contexts
+// and translations are filled in from texts when present
+func WritePO(w io.Writer, locale string, entries []Entry, texts Table) error {
+	b := bufio.NewWriter(w)
+
+	fmt.Fprintf(b, "msgid \"\"\nmsgstr \"\"\n")
+	fmt.Fprintf(b, "\"Language: %s\\n\"\n", locale)
+	fmt.Fprintf(b, "\"Content-Type: text/plain; charset=UTF-8\\n\"\n")
+
+	for _, e := range entries {
+		fmt.Fprintf(b, "\n#: %s\n", e.Location)
+		fmt.Fprintf(b, "msgctxt %s\n", poQuote(e.Key))
+		fmt.Fprintf(b, "msgid %s\n", poQuote(e.Text))
+		fmt.Fprintf(b, "msgstr %s\n", poQuote(texts[e.Key]))
+	}
+
+	return b.Flush()
+}
+
+// WriteCSV writes entries as 'key,source,translation,location' rows
+func WriteCSV(w io.Writer, locale string, entries []Entry, texts Table) error {
+	c := csv.NewWriter(w)
+	c.Write([]string{"key", SourceLocale, locale, "location"})
+
+	for _, e := range entries {
+		c.Write([]string{e.Key, e.Text, texts[e.Key], e.Location})
+	}
+
+	c.Flush()
+	return c.Error()
+}
+
+// poQuote quotes a string, multi-line strings are split over several lines
+func poQuote(s string) string {
+	escape := func(s string) string {
+		var buf bytes.Buffer
+
+		for _, r := range s {
+			switch r {
+			case '"':
+				buf.WriteString("\\\"")
+			case '\\':
+				buf.WriteString("\\\\")
+			case '\t':
+				buf.WriteString("\\t")
+			case '\n':
+				buf.WriteString("\\n")
+			default:
+				buf.WriteRune(r)
+			}
+		}
+
+		return buf.String()
+	}
+
+	if !strings.Contains(s, "\n") {
+		return fmt.Sprintf("\"%s\"", escape(s))
+	}
+
+	parts := []string{"\"\""}
+	lines := strings.SplitAfter(s, "\n")
+
+	for _, l := range lines {
+		if l != "" {
+			parts = append(parts, fmt.Sprintf("\"%s\"", escape(l)))
+		}
+	}
+
+	return strings.Join(parts, "\n")
+}
@@ -0,0 +1,121 @@
+// Package lang provides string tables with translated texts and extracts
+// translatable strings from quests and dialogues.
+package lang
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// SourceLocale is the language texts are written in
+	SourceLocale = "en"
+
+	// KeyPrefix marks a text which refers to a string table entry, e.g. '@ui.continue'
+	KeyPrefix = "@"
+)
+
+// Tables lists string tables of each locale, stored as 'lang/<locale>/<table>.yaml',
+// keys are prefixed by the table name
+var Tables = []string{"ui", "levels", "quests", "dialogues"}
+
+// Table maps keys to texts of a single locale
+type Table map[string]string
+
+// Load reads string tables of a locale, read returns nil for missing files
+func Load(locale string, read func(name string) []byte) (Table, error) {
+	t := Table{}
+
+	for _, name := range Tables {
+		data := read(fmt.Sprintf("lang/%s/%s.yaml", locale, name))
+
+		if data == nil {
+			continue
+		}
+
+		if err := t.Add(name, data); err != nil {
+			return nil, fmt.Errorf("lang/%s/%s.yaml: %s", locale, name, err)
+		}
+	}
+
+	return t, nil
+}
+
+// Add decodes a string table, nested maps are flattened into dotted keys
+func (t Table) Add(prefix string, data []byte) error {
+	var root yaml.MapSlice
+
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return err
+	}
+
+	return t.flatten(prefix, root)
+}
+
+func (t Table) flatten(prefix string, items yaml.MapSlice) error {
+	for _, it := range items {
+		key := fmt.Sprintf("%s.%v", prefix, it.Key)
+
+		switch v := it.Value.(type) {
+		case yaml.MapSlice:
+			if err := t.flatten(key, v); err != nil {
+				return err
+			}
+		case string:
+			t[key] = strings.TrimRight(v, "\n")
+		case nil:
+			return fmt.Errorf("key '%s' has no text", key)
+		default:
+			t[key] = fmt.Sprint(v)
+		}
+	}
+
+	return nil
+}
+
+// Keys returns table keys in sorted order
+func (t Table) Keys() []string {
+	keys := make([]string, 0, len(t))
+
+	for k := range t {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
+// Catalog looks up texts in a locale and falls back to the source language
+type Catalog struct {
+	Locale string
+	texts  Table
+	source Table
+}
+
+// NewCatalog creates a catalog from the tables of a locale and the source language
+func NewCatalog(locale string, texts, source Table) *Catalog {
+	return &Catalog{
+		Locale: locale,
+		texts:  texts,
+		source: source,
+	}
+}
+
+// Lookup finds the text of a key
+func (c *Catalog) Lookup(key string) (string, bool) {
+	if text, ok := c.texts[key]; ok {
+		return text, true
+	}
+
+	text, ok := c.source[key]
+	return text, ok
+}
+
+// IsKey reports whether a text refers to a string table entry
+func IsKey(text string) bool {
+	return strings.HasPrefix(text, KeyPrefix) && len(text) > len(KeyPrefix) &&
+		!strings.ContainsAny(text, " \t\n")
+}
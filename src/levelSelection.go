@@ -26,7 +26,7 @@ var levelSelection struct {
 func initLevels() {
 	levelSelection.levels = []level{
 		level{
-			title:   "Intro scene",
+			title:   "@levels.intro",
 			mapName: "intro",
 		},
 		level{
@@ -34,20 +34,20 @@ func initLevels() {
 			mapName: "",
 		},
 		level{
-			title:   "Movement test",
+			title:   "@levels.movement",
 			mapName: "movement",
 		},
 		level{
-			title:   "Water particles",
+			title:   "@levels.water",
 			mapName: "water",
 		},
 		level{
-			title:   "Exit game",
+			title:   "@levels.exit",
 			mapName: "$exitGame",
 		},
 	}
 
-	levelSelection.banner = "Debug level selection"
+	levelSelection.banner = "@levels.banner"
 }
 
 func (g *gameMode) drawLevelSelection() {
@@ -56,7 +56,7 @@ func (g *gameMode) drawLevelSelection() {
 	width := system.ScreenWidth
 	start := system.ScreenHeight / 2
 
-	rl.DrawText(levelSelection.banner, 15, 30, 23, rl.RayWhite)
+	rl.DrawText(localizeText(levelSelection.banner), 15, 30, 23, rl.RayWhite)
 
 	// choices
 	chsX := width / 2
@@ -86,7 +86,7 @@ func (g *gameMode) drawLevelSelection() {
 			}
 
 			core.DrawTextCentered(
-				fmt.Sprintf("%s%s", ch.title, mapName),
+				fmt.Sprintf("%s%s", localizeText(ch.title), mapName),
 				chsX,
 				chsY+int32(idx)*ySpacing,
 				16,
@@ -131,6 +131,7 @@ func (g *gameMode) updateLevelSelection() {
 
 	if system.IsKeyPressed("use") {
 		g.quests.quests = []quest{}
+		g.world.reset()
 		g.playLevelSelection()
 
 		//temp
@@ -145,6 +146,7 @@ func (g *gameMode) loadLevel(mapName string) {
 	core.FlushMaps()
 	core.LoadMap(mapName)
 	core.InitMap()
+	g.mapName = mapName
 }
 
 func (g *gameMode) playLevelSelection() {
@@ -155,7 +157,7 @@ func (g *gameMode) playLevelSelection() {
 	}
 
 	if mapName == "$exitGame" {
-		core.CloseGame()
+		quitGame()
 		return
 	}
 
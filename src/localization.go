@@ -0,0 +1,70 @@
+package main
+
+import (
+	"log"
+	"strings"
+
+	"github.com/zaklaus/rurik-prototype/src/lang"
+)
+
+var (
+	// gameLocale is the locale texts are shown in, set by '-locale'
+	gameLocale = lang.SourceLocale
+
+	catalog     = lang.NewCatalog(lang.SourceLocale, lang.Table{}, lang.Table{})
+	missingKeys = map[string]bool{}
+)
+
+// initLocale loads string tables of the selected locale along with the source language
+func initLocale(locale string) {
+	source, err := lang.Load(lang.SourceLocale, readAsset)
+
+	if err != nil {
+		log.Printf("Source strings are broken: %s\n", err)
+		source = lang.Table{}
+	}
+
+	texts := source
+
+	if locale != lang.SourceLocale {
+		texts, err = lang.Load(locale, readAsset)
+
+		if err != nil {
+			log.Printf("Locale '%s' is broken, using '%s': %s\n", locale, lang.SourceLocale, err)
+			texts = lang.Table{}
+		}
+	}
+
+	gameLocale = locale
+	catalog = lang.NewCatalog(locale, texts, source)
+	missingKeys = map[string]bool{}
+	log.Printf("Locale '%s' has been loaded!\n", locale)
+}
+
+// tr returns the text of a string table key, the key itself is returned when missing
+func tr(key string) string {
+	text, ok := catalog.Lookup(key)
+
+	if !ok {
+		if !missingKeys[key] {
+			missingKeys[key] = true
+			log.Printf("String '%s' could not be found in locale '%s'!\n", key, gameLocale)
+		}
+
+		return key
+	}
+
+	return text
+}
+
+// localizeText resolves a text referring to a key ('@ui.continue'),
+// other texts are returned unchanged
+func localizeText(text string) string {
+	trimmed := strings.TrimSpace(text)
+
+	if !lang.IsKey(trimmed) {
+		return text
+	}
+
+	return tr(strings.TrimPrefix(trimmed, lang.KeyPrefix))
+}
@@ -1,9 +1,14 @@
 package main
 
 import (
+	"flag"
+	"os"
+
 	rl "github.com/zaklaus/raylib-go/raylib"
 	"github.com/zaklaus/rurik/src/core"
 	"github.com/zaklaus/rurik/src/system"
+
+	"github.com/zaklaus/rurik-prototype/src/lang"
 )
 
 const (
@@ -23,7 +28,33 @@ var (
 )
 
 func main() {
+	// the quest graph comes with flags of its own
+	if len(os.Args) > 1 && os.Args[1] == "-questgraph" {
+		runQuestGraph(os.Args[2:])
+		return
+	}
+
+	flag.StringVar(&gameLocale, "locale", lang.SourceLocale, "locale the texts are shown in")
+	lsp := flag.Bool("lsp", false, "run the quest language server over the standard input and output")
+	flag.StringVar(&questCoverageFile, "questcoverage", "", "write the quest coverage report to the given file once the game quits")
+	flag.StringVar(&questProgressFile, "questprogress", "", "write the progress of quests to the given file each time the game is saved")
+	flag.Int64Var(&gameSeed, "seed", 0, "seed of quests and the simulation, a random one when 0")
+	flag.StringVar(&questTelemetryFile, "telemetry", "", "append the quest telemetry of the session to the given JSONL file")
+	gendocs := flag.Bool("gendocs", false, "generate the quest command reference and syntax keywords")
+	flag.Parse()
+
+	if *lsp {
+		runLanguageServer(os.Stdin, os.Stdout)
+		return
+	}
+
+	if *gendocs {
+		generateQuestReference()
+		return
+	}
+
 	currentGameMode = &gameMode{}
+	openQuestTelemetry()
 
 	rl.SetTraceLog(0)
 	rl.SetExitKey(0)
@@ -55,5 +86,10 @@ func registerClasses() {
 }
 
 func quitGame() {
+	if questCoverageFile != "" {
+		currentGameMode.quests.profile.writeCoverageReport(questCoverageFile)
+	}
+
+	closeQuestTelemetry()
 	core.CloseGame()
 }
@@ -2,4 +2,5 @@ package main
 
 func registerNatives() {
 	registerQuestNatives()
+	registerWorldNatives()
 }
@@ -1,29 +1,90 @@
 package main
 
-import "github.com/zaklaus/rurik/src/core"
+import (
+	"log"
+	"strings"
+
+	"github.com/zaklaus/rurik/src/core"
+)
 
 func registerQuestNatives() {
 	core.RegisterNative("quest", func(jsData core.InvokeData) interface{} {
 		var data struct {
 			ID        int64
+			Template  string
 			EventName string
-			Args      []float64
+			Args      []interface{}
 		}
 		data.ID = -1
 
 		core.DecodeInvokeData(&data, jsData)
 
-		currentGameMode.quests.callEvent(data.ID, data.EventName, data.Args)
+		args := []questVar{}
+
+		for _, v := range data.Args {
+			val, ok := toQuestVar(v)
+
+			if !ok {
+				log.Printf("Event '%s' can't accept argument of type '%T'!\n", data.EventName, v)
+				return nil
+			}
+
+			args = append(args, val)
+		}
+
+		if data.Template != "" {
+			currentGameMode.quests.callTemplateEvent(data.Template, data.EventName, args)
+		} else {
+			currentGameMode.quests.callEvent(data.ID, data.EventName, args)
+		}
+
+		return nil
+	})
+
+	core.RegisterNative("setQuestLimit", func(jsData core.InvokeData) interface{} {
+		var data struct {
+			Category string
+			Limit    int
+		}
+		core.DecodeInvokeData(&data, jsData)
+
+		cat, ok := questCategories[strings.ToLower(data.Category)]
+
+		if !ok {
+			log.Printf("Quest category '%s' does not exist!\n", data.Category)
+			return nil
+		}
+
+		questCategoryLimits[cat] = data.Limit
 		return nil
 	})
 
 	core.RegisterNative("addQuest", func(jsData core.InvokeData) interface{} {
 		var data struct {
-			Name string
+			Name   string
+			Params map[string]interface{}
 		}
 		core.DecodeInvokeData(&data, jsData)
 
-		_, _, id := currentGameMode.quests.addQuest(data.Name, nil)
+		details := map[string]questVar{}
+
+		for k, v := range data.Params {
+			val, ok := toQuestVar(v)
+
+			if !ok {
+				log.Printf("Quest '%s' can't accept parameter '%s' of type '%T'!\n", data.Name, k, v)
+				return int64(-1)
+			}
+
+			details[k] = val
+		}
+
+		ok, reason, id := currentGameMode.quests.addQuest(data.Name, details)
+
+		if !ok {
+			log.Printf("Quest '%s' could not be started: %s\n", data.Name, reason)
+		}
+
 		return id
 	})
 }
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"log"
+
+	rl "github.com/zaklaus/raylib-go/raylib"
+	"github.com/zaklaus/rurik/src/core"
+)
+
+func registerWorldNatives() {
+	core.RegisterNative("getFlag", func(jsData core.InvokeData) interface{} {
+		var data struct {
+			Name string
+		}
+		core.DecodeInvokeData(&data, jsData)
+
+		val, ok := currentGameMode.world.get(data.Name)
+
+		if !ok {
+			return nil
+		}
+
+		return flagToScript(val)
+	})
+
+	core.RegisterNative("setFlag", func(jsData core.InvokeData) interface{} {
+		var data struct {
+			Name  string
+			Value interface{}
+		}
+		core.DecodeInvokeData(&data, jsData)
+
+		switch v := data.Value.(type) {
+		case float64:
+			currentGameMode.world.setNumber(data.Name, v)
+		case int64:
+			currentGameMode.world.setNumber(data.Name, float64(v))
+		case int:
+			currentGameMode.world.setNumber(data.Name, float64(v))
+		case bool:
+			num := 0.0
+
+			if v {
+				num = 1
+			}
+
+			currentGameMode.world.setNumber(data.Name, num)
+		case string:
+			currentGameMode.world.setString(data.Name, v)
+		case []interface{}:
+			vec, ok := toVector(v)
+
+			if !ok {
+				log.Printf("Flag '%s' expects a vector in form of [x, y]!\n", data.Name)
+				return nil
+			}
+
+			currentGameMode.world.setVector(data.Name, vec)
+		default:
+			log.Printf("Flag '%s' can't hold a value of type '%T'!\n", data.Name, data.Value)
+		}
+
+		return nil
+	})
+
+	core.RegisterNative("setLocale", func(jsData core.InvokeData) interface{} {
+		var data struct {
+			Locale string
+		}
+		core.DecodeInvokeData(&data, jsData)
+
+		initLocale(data.Locale)
+		return nil
+	})
+
+	core.RegisterNative("translate", func(jsData core.InvokeData) interface{} {
+		var data struct {
+			Key string
+		}
+		core.DecodeInvokeData(&data, jsData)
+
+		return tr(data.Key)
+	})
+}
+
+// flagToScript converts a flag to the form setFlag takes, vectors are passed as [x, y]
+func flagToScript(val questVar) interface{} {
+	switch val.kind {
+	case kindNumber:
+		return val.value.(*questVarNumber).value
+	case kindString:
+		return val.value.(*questVarString).value
+	case kindVector:
+		vec := val.value.(*questVarVector).value
+		return []interface{}{float64(vec.X), float64(vec.Y)}
+	}
+
+	return nil
+}
+
+func toVector(v []interface{}) (rl.Vector2, bool) {
+	if len(v) != 2 {
+		return rl.Vector2{}, false
+	}
+
+	res := [2]float32{}
+
+	for i, c := range v {
+		switch n := c.(type) {
+		case float64:
+			res[i] = float32(n)
+		case int64:
+			res[i] = float32(n)
+		case int:
+			res[i] = float32(n)
+		default:
+			return rl.Vector2{}, false
+		}
+	}
+
+	return rl.NewVector2(res[0], res[1]), true
+}
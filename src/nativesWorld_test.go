@@ -0,0 +1,36 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	rl "github.com/zaklaus/raylib-go/raylib"
+)
+
+func TestFlagToScript(t *testing.T) {
+	tests := []struct {
+		val  questVar
+		want interface{}
+	}{
+		{makeQuestVarNumber(3), 3.0},
+		{makeQuestVarString("Alice"), "Alice"},
+		{makeQuestVarVector(rl.NewVector2(12, 34)), []interface{}{12.0, 34.0}},
+	}
+
+	for _, tc := range tests {
+		got := flagToScript(tc.val)
+
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("got %#v, want %#v", got, tc.want)
+		}
+	}
+}
+
+func TestFlagVectorRoundTrip(t *testing.T) {
+	want := rl.NewVector2(120, 40)
+	vec, ok := toVector(flagToScript(makeQuestVarVector(want)).([]interface{}))
+
+	if !ok || vec != want {
+		t.Errorf("got %v, want %v back", vec, want)
+	}
+}
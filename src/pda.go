@@ -43,12 +43,17 @@ type pdaAppBase struct {
 }
 
 func makePDA() pdaSystem {
-	return pdaSystem{
+	p := pdaSystem{
 		frameTexture:       system.GetTexture("gfx/pda.png"),
 		currentTimeAndDate: time.Now(),
-		installedApps:      []pdaApp{},
-		activeApp:          nil,
+		installedApps: []pdaApp{
+			makePDAJournal(),
+		},
 	}
+
+	p.activeApp = &p.installedApps[0]
+
+	return p
 }
 
 func drawPDA(g *gameMode) {
@@ -63,8 +68,16 @@ func drawPDA(g *gameMode) {
 		0,
 		rl.White,
 	)
+
+	if p.activeApp != nil {
+		(*p.activeApp).render()
+	}
 }
 
 func updatePDA(g *gameMode) {
+	p := g.pda
 
+	if p.activeApp != nil {
+		(*p.activeApp).update()
+	}
 }
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	rl "github.com/zaklaus/raylib-go/raylib"
+	"github.com/zaklaus/rurik/src/system"
+)
+
+const (
+	pdaJournalFontSize   = 10
+	pdaJournalLineHeight = 12
+	pdaJournalListWidth  = 140
+)
+
+type pdaJournal struct {
+	pdaAppBase
+	selected int
+}
+
+func makePDAJournal() *pdaJournal {
+	return &pdaJournal{
+		pdaAppBase: pdaAppBase{
+			title: "@ui.journal.title",
+		},
+	}
+}
+
+func (j *pdaJournal) on() {
+	j.selected = 0
+}
+
+func (j *pdaJournal) off() {}
+
+// journalQuests lists active quests, main quests go first
+func journalQuests() []*quest {
+	qs := currentGameMode.quests.getActiveQuests()
+
+	sort.SliceStable(qs, func(i, j int) bool {
+		return qs[i].category < qs[j].category
+	})
+
+	return qs
+}
+
+func (j *pdaJournal) update() {
+	qs := journalQuests()
+	count := len(qs)
+
+	if system.IsKeyPressed("up") {
+		j.selected--
+	}
+
+	if system.IsKeyPressed("down") {
+		j.selected++
+	}
+
+	if j.selected >= count {
+		j.selected = count - 1
+	}
+
+	if j.selected < 0 {
+		j.selected = 0
+	}
+
+	if j.selected >= count {
+		return
+	}
+
+	sel := qs[j.selected]
+
+	if system.IsKeyPressed("use") {
+		currentGameMode.quests.trackedQuest = sel.ID
+	}
+
+	if rl.IsKeyPressed(rl.KeyDelete) {
+		currentGameMode.quests.abandonQuest(sel.ID)
+	}
+
+	if rl.IsKeyPressed(rl.KeyR) {
+		if ok, reason, _ := currentGameMode.quests.restartQuest(sel.ID); !ok {
+			PushNotification(reason, rl.Red)
+		}
+	}
+}
+
+func (j *pdaJournal) render() {
+	screenX, screenY := pdaLayoutX+pdaScreenX, pdaLayoutY+pdaScreenY
+	x := int32(screenX) + 5
+	y := int32(screenY) + 5
+
+	rl.DrawText(localizeText(j.title), x, y, pdaJournalFontSize*2, rl.RayWhite)
+	y += pdaJournalFontSize*2 + 5
+
+	qs := journalQuests()
+
+	if len(qs) == 0 {
+		rl.DrawText(tr("ui.journal.empty"), x, y, pdaJournalFontSize, rl.Gray)
+		return
+	}
+
+	rl.DrawText(tr("ui.journal.hint"), x, int32(screenY+pdaScreenHeight)-pdaJournalLineHeight-5, pdaJournalFontSize, rl.Gray)
+
+	for i, v := range qs {
+		color := rl.Gray
+
+		if i == j.selected {
+			color = rl.Orange
+		}
+
+		title := v.processText(v.title)
+
+		if v.ID == currentGameMode.quests.trackedQuest {
+			title = "> " + title
+		}
+
+		rl.DrawText(title, x, y+int32(i*pdaJournalLineHeight), pdaJournalFontSize, color)
+	}
+
+	if j.selected >= len(qs) {
+		return
+	}
+
+	// briefing can refer to quest parameters using %param%
+	sel := qs[j.selected]
+	lines := strings.Split(sel.processText(sel.briefing), "\n")
+
+	for _, line := range lines {
+		rl.DrawText(line, x+pdaJournalListWidth, y, pdaJournalFontSize, rl.RayWhite)
+		y += pdaJournalLineHeight
+	}
+
+	objectives := sel.getObjectives()
+
+	if len(objectives) == 0 {
+		return
+	}
+
+	y += pdaJournalLineHeight
+	rl.DrawText(tr("ui.journal.objectives"), x+pdaJournalListWidth, y, pdaJournalFontSize, rl.Orange)
+
+	for _, v := range objectives {
+		y += pdaJournalLineHeight
+		text := fmt.Sprintf("%s %s", v.marker(), v.text(sel))
+		rl.DrawText(text, x+pdaJournalListWidth, y, pdaJournalFontSize, stageNotificationColors[v.state])
+	}
+}
@@ -0,0 +1,87 @@
+package qstfmt
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files with the current output")
+
+// questFiles lists quests shipped with the game
+func questFiles(t *testing.T) []string {
+	files, err := filepath.Glob(filepath.Join("..", "..", "assets", "quests", "*.qst"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(files) == 0 {
+		t.Fatal("no quest files found")
+	}
+
+	return files
+}
+
+func formatFile(t *testing.T, path string) []byte {
+	data, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := Format(data)
+
+	if err != nil {
+		t.Fatalf("%s: %s", path, err)
+	}
+
+	return res
+}
+
+func TestFormatGolden(t *testing.T) {
+	for _, path := range questFiles(t) {
+		name := strings.TrimSuffix(filepath.Base(path), ".qst")
+
+		t.Run(name, func(t *testing.T) {
+			res := formatFile(t, path)
+			golden := filepath.Join("testdata", name+".golden")
+
+			if *update {
+				if err := ioutil.WriteFile(golden, res, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			want, err := ioutil.ReadFile(golden)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(res, want) {
+				t.Errorf("output differs from %s, run 'go test -update' after checking the change:\n%s", golden, res)
+			}
+		})
+	}
+}
+
+func TestFormatIdempotent(t *testing.T) {
+	for _, path := range questFiles(t) {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			once := formatFile(t, path)
+			twice, err := Format(once)
+
+			if err != nil {
+				t.Fatalf("formatted output can't be parsed: %s", err)
+			}
+
+			if !bytes.Equal(once, twice) {
+				t.Errorf("formatting twice gives a different result:\n%s", twice)
+			}
+		})
+	}
+}
@@ -0,0 +1,365 @@
+package qstfmt
+
+import (
+	"fmt"
+	"strings"
+)
+
+type parser struct {
+	lines []string
+	pos   int
+}
+
+// Parse builds the syntax tree of a quest file
+func Parse(data []byte) (*File, error) {
+	text := strings.TrimPrefix(string(data), "\ufeff")
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+
+	p := &parser{
+		lines: strings.Split(text, "\n"),
+	}
+
+	f := &File{}
+
+	for p.pos < len(p.lines) {
+		n, err := p.parseTopLevel()
+
+		if err != nil {
+			return nil, err
+		}
+
+		if n != nil {
+			f.Nodes = append(f.Nodes, n)
+		}
+	}
+
+	return f, nil
+}
+
+func (p *parser) line() string {
+	return strings.TrimSpace(p.lines[p.pos])
+}
+
+// keyword returns the lowercase keyword of a 'KEYWORD: value' line and the value
+func keyword(line string) (string, string) {
+	sep := strings.Index(line, ":")
+
+	if sep == -1 {
+		return "", ""
+	}
+
+	kw := strings.TrimSpace(line[:sep])
+
+	if kw == "" || strings.ContainsAny(kw, " \t") {
+		return "", ""
+	}
+
+	return strings.ToLower(kw), strings.TrimSpace(line[sep+1:])
+}
+
+// isSectionStart checks whether a line starts a new top-level element
+func isSectionStart(line string) bool {
+	if strings.HasPrefix(line, "+") || macroHeaderRegex.MatchString(line) {
+		return true
+	}
+
+	kw, _ := keyword(line)
+	_, ok := headerKeywords[kw]
+	return ok
+}
+
+func (p *parser) parseTopLevel() (*Node, error) {
+	line := p.line()
+	n := &Node{Line: p.pos + 1}
+
+	switch {
+	case line == "":
+		p.pos++
+		return nil, nil
+
+	case strings.HasPrefix(line, commentPrefix):
+		n.Kind = Comment
+		n.Comment = strings.TrimSpace(line[len(commentPrefix):])
+		p.pos++
+
+	case strings.HasPrefix(line, "+"):
+		line, n.Comment = splitComment(line)
+		fields := strings.Fields(line)
+		n.Kind = Flag
+		n.Keyword = strings.ToUpper(fields[0])
+		n.Value = strings.Join(fields[1:], " ")
+		p.pos++
+
+	case macroHeaderRegex.MatchString(line):
+		return p.parseMacro()
+
+	default:
+		kw, value := keyword(line)
+		kind, ok := headerKeywords[kw]
+
+		if !ok {
+			return nil, fmt.Errorf("line %d: unexpected '%s'", p.pos+1, line)
+		}
+
+		n.Kind = kind
+		n.Keyword = strings.ToUpper(kw)
+		p.pos++
+
+		switch kind {
+		case Header:
+			// titles keep their spacing, the game shows them as written
+			n.Value, n.Comment = splitComment(value)
+
+		case Block:
+			n.Children = p.parseBlock(kw, value)
+
+		case Section:
+			if kw == "qrc" {
+				return n, p.parseResources(n)
+			}
+
+			return n, p.parseTasks(n)
+		}
+	}
+
+	return n, nil
+}
+
+// parseBlock reads a text block, constants may be separated by blank lines
+func (p *parser) parseBlock(kw, first string) []*Node {
+	res := []*Node{}
+
+	if first != "" {
+		res = append(res, &Node{Kind: Text, Value: first, Line: p.pos})
+	} else {
+		p.skipBlank()
+	}
+
+	for p.pos < len(p.lines) {
+		line := p.line()
+
+		if line == "" {
+			if kw != "def" {
+				break
+			}
+
+			p.skipBlank()
+			continue
+		}
+
+		if kw == "def" && isSectionStart(line) {
+			break
+		}
+
+		if kw == "def" || kw == "params" {
+			line = normalizeWords(line)
+		}
+
+		res = append(res, &Node{Kind: Text, Value: line, Line: p.pos + 1})
+		p.pos++
+	}
+
+	return res
+}
+
+func (p *parser) skipBlank() {
+	for p.pos < len(p.lines) && p.line() == "" {
+		p.pos++
+	}
+}
+
+func (p *parser) parseResources(section *Node) error {
+	for p.skipBlank(); p.pos < len(p.lines); p.skipBlank() {
+		line := p.line()
+
+		if strings.HasPrefix(line, commentPrefix) {
+			section.Children = append(section.Children, &Node{
+				Kind:    Comment,
+				Comment: strings.TrimSpace(line[len(commentPrefix):]),
+				Line:    p.pos + 1,
+			})
+
+			p.pos++
+			continue
+		}
+
+		kw, value := keyword(line)
+
+		if !resourceKinds[kw] {
+			if isSectionStart(line) {
+				return nil
+			}
+
+			return fmt.Errorf("line %d: resource expected, got '%s'", p.pos+1, line)
+		}
+
+		n := &Node{
+			Kind:    Resource,
+			Keyword: strings.ToUpper(kw),
+			Line:    p.pos + 1,
+		}
+
+		value, n.Comment = splitComment(value)
+
+		if attrs := strings.Index(value, "("); attrs != -1 {
+			n.Value = normalizeList(strings.Trim(value[attrs:], "()"))
+			value = value[:attrs]
+		}
+
+		n.Name = strings.TrimSpace(value)
+		p.pos++
+		p.skipBlank()
+
+		for p.pos < len(p.lines) && p.line() != "" {
+			n.Children = append(n.Children, &Node{Kind: Text, Value: p.line(), Line: p.pos + 1})
+			p.pos++
+		}
+
+		section.Children = append(section.Children, n)
+	}
+
+	return nil
+}
+
+// parseTasks reads the entry point's commands followed by tasks
+func (p *parser) parseTasks(section *Node) error {
+	var task *Node
+	p.skipBlank()
+
+	for p.pos < len(p.lines) {
+		line := p.line()
+		target := section
+
+		if task != nil {
+			target = task
+		}
+
+		if line == "" {
+			target.Children = append(target.Children, &Node{Kind: Blank, Line: p.pos + 1})
+			p.pos++
+			continue
+		}
+
+		if isSectionStart(line) {
+			return nil
+		}
+
+		if t := parseTaskHeader(line); t != nil {
+			t.Line = p.pos + 1
+			task = t
+			section.Children = append(section.Children, t)
+			p.pos++
+			continue
+		}
+
+		target.Children = append(target.Children, parseCommand(line, p.pos+1))
+		p.pos++
+	}
+
+	return nil
+}
+
+// parseTaskHeader handles 'task <name> (attrs):', 'event <name>:' and 'ON_ERROR:'
+func parseTaskHeader(line string) *Node {
+	line, comment := splitComment(line)
+
+	if !strings.HasSuffix(line, ":") {
+		return nil
+	}
+
+	fields := strings.Fields(strings.TrimSuffix(line, ":"))
+
+	// the game only ends a task body at a lower case 'task' or 'event'
+	if len(fields) == 0 || !(taskKeywords[fields[0]] || strings.EqualFold(fields[0], "on_error")) {
+		return nil
+	}
+
+	n := &Node{
+		Kind:    Task,
+		Keyword: fields[0],
+		Comment: comment,
+	}
+
+	if strings.EqualFold(n.Keyword, "on_error") {
+		n.Keyword = "ON_ERROR"
+
+		if len(fields) != 1 {
+			return nil
+		}
+
+		return n
+	}
+
+	if len(fields) < 2 {
+		return nil
+	}
+
+	n.Name = fields[1]
+
+	if len(fields) > 2 {
+		n.Value = normalizeList(strings.Trim(strings.Join(fields[2:], " "), "()"))
+	}
+
+	return n
+}
+
+func parseCommand(line string, num int) *Node {
+	if strings.HasPrefix(line, commentPrefix) {
+		return &Node{
+			Kind:    Comment,
+			Comment: strings.TrimSpace(line[len(commentPrefix):]),
+			Line:    num,
+		}
+	}
+
+	line, comment := splitComment(line)
+	name := line
+	args := ""
+
+	if sep := strings.IndexAny(line, " \t"); sep != -1 {
+		name, args = line[:sep], line[sep+1:]
+	}
+
+	// 'Task' is a command to the game, lowering it would start a new task
+	if kw := strings.ToLower(name); !taskKeywords[kw] {
+		name = kw
+	}
+
+	return &Node{
+		Kind:    Command,
+		Keyword: name,
+		Value:   normalizeWords(args),
+		Comment: comment,
+		Line:    num,
+	}
+}
+
+// parseMacro reads 'macro name(params):' followed by commands up to 'end'
+func (p *parser) parseMacro() (*Node, error) {
+	header := macroHeaderRegex.FindStringSubmatch(p.line())
+	n := &Node{
+		Kind:    Macro,
+		Keyword: "macro",
+		Name:    header[1],
+		Value:   normalizeList(header[2]),
+		Line:    p.pos + 1,
+	}
+
+	for p.pos++; p.pos < len(p.lines); p.pos++ {
+		line := p.line()
+
+		if strings.EqualFold(line, "end") {
+			p.pos++
+			return n, nil
+		}
+
+		if line == "" {
+			n.Children = append(n.Children, &Node{Kind: Blank, Line: p.pos + 1})
+			continue
+		}
+
+		n.Children = append(n.Children, parseCommand(line, p.pos+1))
+	}
+
+	return nil, fmt.Errorf("line %d: macro '%s' is missing its 'end'", n.Line, n.Name)
+}
@@ -0,0 +1,276 @@
+package qstfmt
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+/*
+	Canonical layout:
+	- flags and single line headers come first, one per line
+	- text blocks, sections, macros and tasks are separated by a blank line
+	- entry point commands are not indented, task and macro bodies are indented by 4 spaces
+	- keywords are upper case, task keywords and commands lower case
+	- blank lines within task bodies are kept, but never more than one in a row
+	- comments stick to the element that follows them
+*/
+
+type printer struct {
+	buf bytes.Buffer
+}
+
+// Format parses a quest file and prints it in canonical form
+func Format(data []byte) ([]byte, error) {
+	f, err := Parse(data)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return f.Bytes(), nil
+}
+
+// Bytes prints the syntax tree in canonical form
+func (f *File) Bytes() []byte {
+	p := &printer{}
+	var prev *Node
+
+	for i, n := range f.Nodes {
+		if prev != nil && f.Nodes[i-1].Kind != Comment && (endsBlock(prev) || startsBlock(nextNode(f.Nodes, i))) {
+			p.blank()
+		}
+
+		p.node(n)
+
+		if n.Kind != Comment {
+			prev = n
+		}
+	}
+
+	return p.buf.Bytes()
+}
+
+// endsBlock tells whether the node has to be followed by a blank line,
+// text blocks run up to the first blank line
+func endsBlock(n *Node) bool {
+	return n.Kind != Flag && n.Kind != Header
+}
+
+// startsBlock tells whether the node has to be preceded by a blank line,
+// the briefing stays with the title
+func startsBlock(n *Node) bool {
+	if n == nil || n.Kind == Flag || n.Kind == Header {
+		return false
+	}
+
+	return n.Kind != Block || n.Keyword != "BRIEFING"
+}
+
+// nextNode returns the first node after i which isn't a comment
+func nextNode(nodes []*Node, i int) *Node {
+	for ; i < len(nodes); i++ {
+		if nodes[i].Kind != Comment {
+			return nodes[i]
+		}
+	}
+
+	return nil
+}
+
+func (p *printer) blank() {
+	p.buf.WriteString("\n")
+}
+
+func (p *printer) printf(prefix string, format string, args ...interface{}) {
+	p.buf.WriteString(prefix)
+	fmt.Fprintf(&p.buf, format, args...)
+}
+
+// end finishes a line with the node's trailing comment
+func (p *printer) end(n *Node) {
+	if n.Comment != "" {
+		p.buf.WriteString(" " + commentPrefix + " " + n.Comment)
+	}
+
+	p.buf.WriteString("\n")
+}
+
+func (p *printer) node(n *Node) {
+	switch n.Kind {
+	case Comment:
+		p.comment("", n)
+
+	case Flag:
+		p.printf("", "%s", join(n.Keyword, n.Value))
+		p.end(n)
+
+	case Header:
+		p.printf("", "%s:", n.Keyword)
+
+		if n.Value != "" {
+			p.printf(" ", "%s", n.Value)
+		}
+
+		p.end(n)
+
+	case Block:
+		p.printf("", "%s:", n.Keyword)
+		lines := n.Children
+
+		// the briefing starts on the same line, other blocks hold one item per line
+		if n.Keyword == "BRIEFING" && len(lines) > 0 {
+			p.printf(" ", "%s", lines[0].Value)
+			lines = lines[1:]
+		}
+
+		p.buf.WriteString("\n")
+
+		for _, v := range lines {
+			p.printf("", "%s\n", v.Value)
+		}
+
+	case Section:
+		p.printf("", "%s:\n", n.Keyword)
+
+		if n.Keyword == "QRC" {
+			p.resources(n)
+		} else {
+			p.tasks(n)
+		}
+
+	case Macro:
+		p.printf("", "macro %s(%s):\n", n.Name, n.Value)
+		p.body(n.Children)
+		p.printf("", "end\n")
+	}
+}
+
+func (p *printer) comment(prefix string, n *Node) {
+	if n.Comment == "" {
+		p.printf(prefix, "%s\n", commentPrefix)
+		return
+	}
+
+	p.printf(prefix, "%s %s\n", commentPrefix, n.Comment)
+}
+
+func (p *printer) resources(section *Node) {
+	prevComment := false
+
+	for _, n := range section.Children {
+		if !prevComment {
+			p.blank()
+		}
+
+		if n.Kind == Comment {
+			p.comment("", n)
+			prevComment = true
+			continue
+		}
+
+		prevComment = false
+		p.printf("", "%s: %s", n.Keyword, n.Name)
+
+		if n.Value != "" {
+			p.printf(" ", "(%s)", n.Value)
+		}
+
+		p.end(n)
+
+		for _, v := range n.Children {
+			p.printf("", "%s\n", v.Value)
+		}
+	}
+}
+
+func (p *printer) tasks(section *Node) {
+	entry := []*Node{}
+	tasks := []*Node{}
+
+	for _, n := range section.Children {
+		if n.Kind == Task {
+			tasks = append(tasks, n)
+		} else {
+			entry = append(entry, n)
+		}
+	}
+
+	entry, lead := leadingComments(trimBlank(entry), len(tasks) > 0)
+
+	if len(entry) > 0 {
+		p.blank()
+		p.commands("", entry)
+	}
+
+	for i, t := range tasks {
+		body, next := leadingComments(trimBlank(t.Children), i+1 < len(tasks))
+
+		p.blank()
+		p.commands("", lead)
+		p.printf("", "%s", join(t.Keyword, t.Name))
+
+		if t.Value != "" {
+			p.printf(" ", "(%s)", t.Value)
+		}
+
+		p.buf.WriteString(":")
+		p.end(t)
+		p.body(body)
+		lead = next
+	}
+}
+
+// leadingComments splits off comments separated from the end of a body by a blank line,
+// they describe the task that follows
+func leadingComments(nodes []*Node, taskFollows bool) ([]*Node, []*Node) {
+	i := len(nodes)
+
+	for i > 0 && nodes[i-1].Kind == Comment {
+		i--
+	}
+
+	if !taskFollows || i == len(nodes) || (i > 0 && nodes[i-1].Kind != Blank) {
+		return nodes, nil
+	}
+
+	return trimBlank(nodes[:i]), nodes[i:]
+}
+
+// body prints commands of a task or macro
+func (p *printer) body(nodes []*Node) {
+	p.commands(indent, trimBlank(nodes))
+}
+
+func (p *printer) commands(prefix string, nodes []*Node) {
+	for i, n := range nodes {
+		switch n.Kind {
+		case Blank:
+			if i > 0 && nodes[i-1].Kind != Blank {
+				p.blank()
+			}
+		case Comment:
+			p.comment(prefix, n)
+		case Command:
+			p.printf(prefix, "%s", join(n.Keyword, n.Value))
+			p.end(n)
+		}
+	}
+}
+
+// trimBlank removes blank lines at the start and the end of a body
+func trimBlank(nodes []*Node) []*Node {
+	for len(nodes) > 0 && nodes[0].Kind == Blank {
+		nodes = nodes[1:]
+	}
+
+	for len(nodes) > 0 && nodes[len(nodes)-1].Kind == Blank {
+		nodes = nodes[:len(nodes)-1]
+	}
+
+	return nodes
+}
+
+func join(a, b string) string {
+	return strings.TrimSpace(a + " " + b)
+}
@@ -0,0 +1,187 @@
+// Package qstfmt formats quest files into their canonical form.
+//
+// Files are parsed into a syntax tree which keeps every word and comment,
+// only the layout is decided by the printer.
+package qstfmt
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Kind is the kind of a syntax tree node
+type Kind int
+
+// Node kinds
+const (
+	// Blank is an empty line, kept only within task bodies
+	Blank Kind = iota
+
+	// Comment is a line starting with '$-'
+	Comment
+
+	// Flag is a line starting with '+', e.g. '+REPEATABLE cooldown=10'
+	Flag
+
+	// Header is a single line header, e.g. 'TITLE: Demo quest'
+	Header
+
+	// Block is a header followed by a text block, e.g. 'BRIEFING:' or 'PARAMS:'
+	Block
+
+	// Section is 'QRC:' or 'QST:', its children are resources or commands and tasks
+	Section
+
+	// Resource is a QRC entry, e.g. 'STAGE: 2000 (optional)', its children are lines of its text
+	Resource
+
+	// Task is a task header, e.g. 'task _S.00_ (dormant):', its children are commands
+	Task
+
+	// Macro is a macro declaration, its children are commands
+	Macro
+
+	// Command is a single command along with its arguments
+	Command
+
+	// Text is a line of a text block
+	Text
+)
+
+const (
+	commentPrefix = "$-"
+	indent        = "    "
+)
+
+var (
+	headerKeywords = map[string]Kind{
+		"title":    Header,
+		"category": Header,
+		"requires": Header,
+		"include":  Header,
+		"briefing": Block,
+		"params":   Block,
+		"def":      Block,
+		"qrc":      Section,
+		"qst":      Section,
+	}
+
+	resourceKinds = map[string]bool{
+		"message": true,
+		"sound":   true,
+		"video":   true,
+		"stage":   true,
+	}
+
+	taskKeywords = map[string]bool{
+		"task":  true,
+		"event": true,
+	}
+
+	macroHeaderRegex = regexp.MustCompile(`^(?i:macro)\s+([A-Za-z_]\w*)\s*\(([^)]*)\)\s*:\s*$`)
+)
+
+// Node is an element of the syntax tree
+type Node struct {
+	Kind Kind
+
+	// Keyword is the header, resource kind, task keyword or command name
+	Keyword string
+
+	// Name is the resource ID, task or macro name
+	Name string
+
+	// Value holds the rest of the line, attributes of resources and tasks or command arguments
+	Value string
+
+	// Comment is a trailing comment, without the '$-' prefix
+	Comment string
+
+	// Line is the line the node starts at, counted from 1
+	Line int
+
+	Children []*Node
+}
+
+// File is a parsed quest file
+type File struct {
+	Nodes []*Node
+}
+
+// splitComment separates a trailing comment, comments start at the beginning of a word
+func splitComment(line string) (string, string) {
+	for _, w := range words(line) {
+		if strings.HasPrefix(line[w[0]:], commentPrefix) {
+			return strings.TrimSpace(line[:w[0]]), strings.TrimSpace(line[w[0]+len(commentPrefix):])
+		}
+	}
+
+	return line, ""
+}
+
+// words splits a line into words the way the quest parser does,
+// words starting with a parenthesis run up to the matching one and may contain spaces
+func words(line string) [][2]int {
+	res := [][2]int{}
+	rs := []rune(line)
+	offs := make([]int, len(rs)+1)
+
+	for i, pos := 0, 0; i < len(rs); i++ {
+		offs[i] = pos
+		pos += len(string(rs[i]))
+		offs[i+1] = pos
+	}
+
+	for i := 0; i < len(rs); {
+		if unicode.IsSpace(rs[i]) {
+			i++
+			continue
+		}
+
+		start := i
+
+		if rs[i] == '(' {
+			depth := 0
+
+			for ; i < len(rs); i++ {
+				if rs[i] == '(' {
+					depth++
+				} else if rs[i] == ')' {
+					depth--
+
+					if depth == 0 {
+						i++
+						break
+					}
+				}
+			}
+		} else {
+			for i < len(rs) && !unicode.IsSpace(rs[i]) {
+				i++
+			}
+		}
+
+		res = append(res, [2]int{offs[start], offs[i]})
+	}
+
+	return res
+}
+
+// normalizeWords joins the line's words by single spaces
+func normalizeWords(line string) string {
+	parts := []string{}
+
+	for _, w := range words(line) {
+		parts = append(parts, line[w[0]:w[1]])
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// normalizeList formats a comma separated list, e.g. attributes or macro params
+func normalizeList(list string) string {
+	return strings.Join(strings.FieldsFunc(list, func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	}), ", ")
+}
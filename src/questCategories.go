@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Quest categories
+const (
+	catMain = iota
+	catSide
+	catTask
+	catBackground
+)
+
+var questCategories = map[string]int{
+	"main":       catMain,
+	"side":       catSide,
+	"task":       catTask,
+	"background": catBackground,
+}
+
+// questCategoryLimits is the maximum number of quests running at once per category,
+// zero or less means there's no limit
+var questCategoryLimits = map[int]int{
+	catMain:       2,
+	catSide:       5,
+	catTask:       10,
+	catBackground: 0,
+}
+
+func questCategoryName(cat int) string {
+	for k, v := range questCategories {
+		if v == cat {
+			return k
+		}
+	}
+
+	return "unknown"
+}
+
+func (q *questManager) countQuests(check func(qs *quest) bool) int {
+	count := 0
+
+	for i := range q.quests {
+		if v := &q.quests[i]; v.state == qsInProgress && check(v) {
+			count++
+		}
+	}
+
+	for i := range q.pendingQuests {
+		if v := &q.pendingQuests[i]; v.state == qsInProgress && check(v) {
+			count++
+		}
+	}
+
+	return count
+}
+
+// canStartQuest checks the quest's category limit and its flags, it returns the refusal reason
+func (q *questManager) canStartQuest(tplName string, qd *questDef) (bool, string) {
+	if limit := questCategoryLimits[qd.category]; limit > 0 {
+		count := q.countQuests(func(qs *quest) bool {
+			return qs.category == qd.category
+		})
+
+		if count >= limit {
+			return false, fmt.Sprintf("Maximum number of %s quests has been reached!", questCategoryName(qd.category))
+		}
+	}
+
+	if qd.unique {
+		count := q.countQuests(func(qs *quest) bool {
+			return strings.EqualFold(qs.name, tplName)
+		})
+
+		if count > 0 {
+			return false, "Quest is already in progress!"
+		}
+	}
+
+	lastFinish, finished := q.lastFinishTime(tplName)
+
+	if !finished {
+		return true, ""
+	}
+
+	if qd.repeatable {
+		if remaining := lastFinish + qd.cooldown - q.time; remaining > 0 {
+			return false, fmt.Sprintf("Quest can be repeated in %d seconds!", int(remaining)+1)
+		}
+
+		return true, ""
+	}
+
+	// unique quests run only once, unless they're repeatable
+	if qd.unique {
+		return false, "Quest has already been finished!"
+	}
+
+	return true, ""
+}
+
+func (q *questManager) lastFinishTime(tplName string) (float32, bool) {
+	var last float32
+	finished := false
+
+	for _, v := range q.quests {
+		if strings.EqualFold(v.name, tplName) && v.state == qsFinished {
+			if !finished || v.finishedAt > last {
+				last = v.finishedAt
+			}
+
+			finished = true
+		}
+	}
+
+	return last, finished
+}
+
+// abandonQuest stops a quest in progress
+func (q *questManager) abandonQuest(id int64) bool {
+	qs := q.findQuest(id)
+
+	if qs == nil || qs.state != qsInProgress {
+		return false
+	}
+
+	qs.setState(qsAbandoned)
+	log.Printf("Quest '%s' has been abandoned!", qs.name)
+
+	return true
+}
+
+// restartQuest abandons a quest and starts it over with the same parameters
+func (q *questManager) restartQuest(id int64) (bool, string, int64) {
+	qs := q.findQuest(id)
+
+	if qs == nil || qs.state != qsInProgress {
+		return false, "Quest is not in progress!", -1
+	}
+
+	name, params := qs.name, qs.params
+
+	// the template can't be started again while the old quest is in progress
+	qs.state = qsAbandoned
+	ok, reason, newID := q.addQuest(name, params)
+
+	old := q.findQuest(id)
+	old.state = qsInProgress
+
+	if !ok {
+		// the old quest carries on when it can't be started over
+		return false, reason, -1
+	}
+
+	old.setState(qsAbandoned)
+
+	log.Printf("Quest '%s' has been restarted!", name)
+
+	return true, "", newID
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/zaklaus/rurik-prototype/src/telemetry"
+)
+
+const restartTestQuest = `TITLE: Delivery
+BRIEFING: Bring %itemCount% pieces of goods to %receiver%.
+
+PARAMS:
+receiver string
+itemCount number 1
+
+QST:
+
+setvar delivered 0
+wait 1000
+`
+
+func TestQuestRestartKeepsParams(t *testing.T) {
+	withQuestFiles(t, map[string]string{
+		"quests/delivery.qst": restartTestQuest,
+	})
+
+	q := &currentGameMode.quests
+	ok, reason, id := q.addQuest("delivery", map[string]questVar{
+		"receiver": makeQuestVarString("Bob"),
+	})
+
+	if !ok {
+		t.Fatalf("quest could not be added: %s", reason)
+	}
+
+	ok, reason, newID := q.restartQuest(id)
+
+	if !ok {
+		t.Fatalf("quest could not be restarted: %s", reason)
+	}
+
+	qs := q.findQuest(newID)
+
+	if len(qs.params) != 2 {
+		t.Errorf("got params %v, want only receiver and itemCount", qs.params)
+	}
+
+	if val, ok := qs.getAnyVariable("receiver"); !ok || val.value.str() != "Bob" {
+		t.Errorf("got receiver = %+v, want 'Bob'", val)
+	}
+
+	if val, ok := qs.getVariable("itemCount"); !ok || val != 1 {
+		t.Errorf("got itemCount = %v, want 1", val)
+	}
+
+	if old := q.findQuest(id); old.state != qsAbandoned {
+		t.Errorf("got state %d of the old quest, want it abandoned", old.state)
+	}
+}
+
+func TestQuestAbandonLogsTelemetry(t *testing.T) {
+	withQuestFiles(t, map[string]string{
+		"quests/delivery.qst": restartTestQuest,
+	})
+
+	path := filepath.Join(t.TempDir(), "telemetry.jsonl")
+	tl, err := telemetry.Open(path)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	questTelemetry = tl
+	defer closeQuestTelemetry()
+
+	q := &currentGameMode.quests
+	ok, reason, id := q.addQuest("delivery", map[string]questVar{
+		"receiver": makeQuestVarString("Bob"),
+	})
+
+	if !ok {
+		t.Fatalf("quest could not be added: %s", reason)
+	}
+
+	if !q.abandonQuest(id) {
+		t.Fatal("quest could not be abandoned")
+	}
+
+	closeQuestTelemetry()
+	f, err := os.Open(path)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer f.Close()
+	events, err := telemetry.Read(f)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n := len(events); n != 2 || events[n-1].Kind != telemetry.KindQuestAbandoned || events[n-1].Run != events[0].Run {
+		t.Errorf("got events %+v, want the quest to be added and abandoned in the same run", events)
+	}
+}
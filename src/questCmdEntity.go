@@ -1,7 +1,7 @@
 package main
 
 func questInitEntityCommands(q *questManager) {
-	/* q.registerCommand("entity", func(qs *quest, qt *questTask, args []string) bool {
+	/* q.registerCommand("entity", func(qs *quest, qt *questTask, args []string) questCommandResult {
 		if len(args) != 2 {
 			return questCommandErrorArgCount("give", qs, qt, len(args), 2)
 		}
@@ -13,6 +13,6 @@ func questInitEntityCommands(q *questManager) {
 		}
 
 		qs.printf(qt, "giving %d of %s", amount, args[0])
-		return true
+		return qcContinue
 	}) */
 }
@@ -6,23 +6,17 @@ import (
 )
 
 func questInitMathCommands(q *questManager) {
-	q.registerCommand("vec", func(qs *quest, qt *questTask, args []string) bool {
-		if len(args) != 1 {
-			return questCommandErrorArgCount("vec", qs, qt, len(args), 1)
-		}
+	q.beginCommandGroup("Vectors")
 
+	q.registerCommand("vec", "<name:vector>", "Declares a new vector", func(qs *quest, qt *questTask, args []string) questCommandResult {
 		vecName := args[0]
 		qs.setVector(vecName, rl.Vector2{})
 
 		qs.printf(qt, "vector '%s' was declared!", vecName)
-		return true
+		return qcContinue
 	})
 
-	q.registerCommand("setvec", func(qs *quest, qt *questTask, args []string) bool {
-		if len(args) < 3 {
-			return questCommandErrorArgCount("setvec", qs, qt, len(args), 3)
-		}
-
+	q.registerCommand("setvec", "<name:vector> <x:number> <y:number>", "Sets components of a vector", func(qs *quest, qt *questTask, args []string) questCommandResult {
 		vecName := args[0]
 
 		xI, _ := qs.getNumberOrVariable(args[1])
@@ -33,41 +27,25 @@ func questInitMathCommands(q *questManager) {
 		qs.setVector(vecName, rl.NewVector2(x, y))
 
 		qs.printf(qt, "vector '%s' was set to [%f, %f]!", vecName, xI, yI)
-		return true
+		return qcContinue
 	})
 
-	q.registerCommand("copyvec", func(qs *quest, qt *questTask, args []string) bool {
-		if len(args) < 2 {
-			return questCommandErrorArgCount("copyvec", qs, qt, len(args), 2)
-		}
-
+	q.registerCommand("copyvec", "<dest:vector> <src:vector>", "Copies a vector", func(qs *quest, qt *questTask, args []string) questCommandResult {
 		vecName := args[0]
 		rhsVecName := args[1]
 
-		rhs, ok := qs.getVector(rhsVecName)
-
-		if !ok {
-			return questCommandErrorThing("copyvec", "vector", qs, qt, rhsVecName)
-		}
+		rhs, _ := qs.getVector(rhsVecName)
 
 		qs.setVector(vecName, rhs)
-		return true
+		return qcContinue
 	})
 
-	q.registerCommand("getvec", func(qs *quest, qt *questTask, args []string) bool {
-		if len(args) < 3 {
-			return questCommandErrorArgCount("getvec", qs, qt, len(args), 3)
-		}
-
+	q.registerCommand("getvec", "<vector:vector> <x:variable> <y:variable>", "Stores components of a vector to variables, `0` skips a component", func(qs *quest, qt *questTask, args []string) questCommandResult {
 		vecName := args[0]
 		xName := args[1]
 		yName := args[2]
 
-		vec, ok := qs.getVector(vecName)
-
-		if !ok {
-			return questCommandErrorThing("getvec", "vector", qs, qt, vecName)
-		}
+		vec, _ := qs.getVector(vecName)
 
 		if xName != "0" {
 			qs.setVariable(xName, float64(vec.X))
@@ -77,259 +55,139 @@ func questInitMathCommands(q *questManager) {
 			qs.setVariable(yName, float64(vec.Y))
 		}
 
-		return true
+		return qcContinue
 	})
 
-	q.registerCommand("addvec", func(qs *quest, qt *questTask, args []string) bool {
-		if len(args) < 3 {
-			return questCommandErrorArgCount("addvec", qs, qt, len(args), 3)
-		}
-
+	q.registerCommand("addvec", "<dest:vector> <lhs:vector> <rhs:vector>", "Adds two vectors", func(qs *quest, qt *questTask, args []string) questCommandResult {
 		destVecName := args[0]
 		lhsVecName := args[1]
 		rhsVecName := args[2]
 
-		lhs, lhsFound := qs.getVector(lhsVecName)
-		rhs, rhsFound := qs.getVector(rhsVecName)
-
-		if !lhsFound {
-			return questCommandErrorThing("addvec", "vector", qs, qt, lhsVecName)
-		}
-
-		if !rhsFound {
-			return questCommandErrorThing("addvec", "vector", qs, qt, rhsVecName)
-		}
+		lhs, _ := qs.getVector(lhsVecName)
+		rhs, _ := qs.getVector(rhsVecName)
 
 		qs.setVector(destVecName, rl.NewVector2(lhs.X+rhs.X, lhs.Y+rhs.Y))
-		return true
+		return qcContinue
 	})
 
-	q.registerCommand("addivec", func(qs *quest, qt *questTask, args []string) bool {
-		if len(args) < 3 {
-			return questCommandErrorArgCount("addivec", qs, qt, len(args), 3)
-		}
-
+	q.registerCommand("addivec", "<dest:vector> <lhs:vector> <rhs:number>", "Adds a number to both components of a vector", func(qs *quest, qt *questTask, args []string) questCommandResult {
 		destVecName := args[0]
 		lhsVecName := args[1]
 
-		lhs, lhsFound := qs.getVector(lhsVecName)
-		rhsI, rhsFound := qs.getNumberOrVariable(args[2])
+		lhs, _ := qs.getVector(lhsVecName)
+		rhsI, _ := qs.getNumberOrVariable(args[2])
 		rhs := float64to32(rhsI)
 
-		if !lhsFound {
-			return questCommandErrorThing("addivec", "vector", qs, qt, lhsVecName)
-		}
-
-		if !rhsFound {
-			return questCommandErrorThing("addivec", "number", qs, qt, args[2])
-		}
-
 		qs.setVector(destVecName, rl.NewVector2(lhs.X+rhs, lhs.Y+rhs))
-		return true
+		return qcContinue
 	})
 
-	q.registerCommand("subvec", func(qs *quest, qt *questTask, args []string) bool {
-		if len(args) < 3 {
-			return questCommandErrorArgCount("subvec", qs, qt, len(args), 3)
-		}
-
+	q.registerCommand("subvec", "<dest:vector> <lhs:vector> <rhs:vector>", "Subtracts two vectors", func(qs *quest, qt *questTask, args []string) questCommandResult {
 		destVecName := args[0]
 		lhsVecName := args[1]
 		rhsVecName := args[2]
 
-		lhs, lhsFound := qs.getVector(lhsVecName)
-		rhs, rhsFound := qs.getVector(rhsVecName)
-
-		if !lhsFound {
-			return questCommandErrorThing("subvec", "vector", qs, qt, lhsVecName)
-		}
-
-		if !rhsFound {
-			return questCommandErrorThing("subvec", "vector", qs, qt, rhsVecName)
-		}
+		lhs, _ := qs.getVector(lhsVecName)
+		rhs, _ := qs.getVector(rhsVecName)
 
 		qs.setVector(destVecName, rl.NewVector2(lhs.X-rhs.X, lhs.Y-rhs.Y))
-		return true
+		return qcContinue
 	})
 
-	q.registerCommand("subivec", func(qs *quest, qt *questTask, args []string) bool {
-		if len(args) < 3 {
-			return questCommandErrorArgCount("subivec", qs, qt, len(args), 3)
-		}
-
+	q.registerCommand("subivec", "<dest:vector> <lhs:vector> <rhs:number>", "Subtracts a number from both components of a vector", func(qs *quest, qt *questTask, args []string) questCommandResult {
 		destVecName := args[0]
 		lhsVecName := args[1]
 
-		lhs, lhsFound := qs.getVector(lhsVecName)
-		rhsI, rhsFound := qs.getNumberOrVariable(args[2])
+		lhs, _ := qs.getVector(lhsVecName)
+		rhsI, _ := qs.getNumberOrVariable(args[2])
 		rhs := float64to32(rhsI)
 
-		if !lhsFound {
-			return questCommandErrorThing("subivec", "vector", qs, qt, lhsVecName)
-		}
-
-		if !rhsFound {
-			return questCommandErrorThing("subivec", "number", qs, qt, args[2])
-		}
-
 		qs.setVector(destVecName, rl.NewVector2(lhs.X-rhs, lhs.Y-rhs))
-		return true
+		return qcContinue
 	})
 
-	q.registerCommand("divivec", func(qs *quest, qt *questTask, args []string) bool {
-		if len(args) < 3 {
-			return questCommandErrorArgCount("divivec", qs, qt, len(args), 3)
-		}
-
+	q.registerCommand("divivec", "<dest:vector> <lhs:vector> <rhs:number>", "Divides a vector by a number", func(qs *quest, qt *questTask, args []string) questCommandResult {
 		destVecName := args[0]
 		lhsVecName := args[1]
 
-		lhs, lhsFound := qs.getVector(lhsVecName)
-		rhsI, rhsFound := qs.getNumberOrVariable(args[2])
+		lhs, _ := qs.getVector(lhsVecName)
+		rhsI, _ := qs.getNumberOrVariable(args[2])
 		rhs := float64to32(rhsI)
 
-		if !lhsFound {
-			return questCommandErrorThing("divivec", "vector", qs, qt, lhsVecName)
-		}
-
-		if !rhsFound {
-			return questCommandErrorThing("divivec", "number", qs, qt, args[2])
-		}
-
 		if rhs == 0 {
 			return questCommandErrorDivideByZero("divivec", qs, qt)
 		}
 
 		qs.setVector(destVecName, rl.NewVector2(lhs.X/rhs, lhs.Y/rhs))
-		return true
+		return qcContinue
 	})
 
-	q.registerCommand("mulvec", func(qs *quest, qt *questTask, args []string) bool {
-		if len(args) < 3 {
-			return questCommandErrorArgCount("mulvec", qs, qt, len(args), 3)
-		}
-
+	q.registerCommand("mulvec", "<dest:vector> <lhs:vector> <rhs:number>", "Multiplies a vector by a number", func(qs *quest, qt *questTask, args []string) questCommandResult {
 		destVecName := args[0]
 		lhsVecName := args[1]
 
-		lhs, lhsFound := qs.getVector(lhsVecName)
-		rhsI, rhsFound := qs.getNumberOrVariable(args[2])
+		lhs, _ := qs.getVector(lhsVecName)
+		rhsI, _ := qs.getNumberOrVariable(args[2])
 		rhs := float64to32(rhsI)
 
-		if !lhsFound {
-			return questCommandErrorThing("mulvec", "vector", qs, qt, lhsVecName)
-		}
-
-		if !rhsFound {
-			return questCommandErrorThing("mulvec", "number", qs, qt, args[2])
-		}
-
 		qs.setVector(destVecName, rl.NewVector2(lhs.X*rhs, lhs.Y*rhs))
-		return true
+		return qcContinue
 	})
 
-	q.registerCommand("dotvec", func(qs *quest, qt *questTask, args []string) bool {
-		if len(args) < 3 {
-			return questCommandErrorArgCount("dotvec", qs, qt, len(args), 3)
-		}
-
+	q.registerCommand("dotvec", "<dest:variable> <lhs:vector> <rhs:vector>", "Stores the dot product of two vectors to a variable", func(qs *quest, qt *questTask, args []string) questCommandResult {
 		destName := args[0]
 		lhsVecName := args[1]
 		rhsVecName := args[2]
 
-		lhs, lhsFound := qs.getVector(lhsVecName)
-		rhs, rhsFound := qs.getVector(rhsVecName)
-
-		if !lhsFound {
-			return questCommandErrorThing("dotvec", "vector", qs, qt, lhsVecName)
-		}
-
-		if !rhsFound {
-			return questCommandErrorThing("dotvec", "number", qs, qt, args[2])
-		}
+		lhs, _ := qs.getVector(lhsVecName)
+		rhs, _ := qs.getVector(rhsVecName)
 
 		res := raymath.Vector2DotProduct(lhs, rhs)
 		qs.setVariable(destName, float64(res))
-		return true
+		return qcContinue
 	})
 
-	q.registerCommand("crossvec", func(qs *quest, qt *questTask, args []string) bool {
-		if len(args) < 3 {
-			return questCommandErrorArgCount("crossvec", qs, qt, len(args), 3)
-		}
-
+	q.registerCommand("crossvec", "<dest:variable> <lhs:vector> <rhs:vector>", "Stores the cross product of two vectors to a variable", func(qs *quest, qt *questTask, args []string) questCommandResult {
 		destName := args[0]
 		lhsVecName := args[1]
 		rhsVecName := args[2]
 
-		lhs, lhsFound := qs.getVector(lhsVecName)
-		rhs, rhsFound := qs.getVector(rhsVecName)
-
-		if !lhsFound {
-			return questCommandErrorThing("crossvec", "vector", qs, qt, lhsVecName)
-		}
-
-		if !rhsFound {
-			return questCommandErrorThing("crossvec", "number", qs, qt, args[2])
-		}
+		lhs, _ := qs.getVector(lhsVecName)
+		rhs, _ := qs.getVector(rhsVecName)
 
 		res := raymath.Vector2CrossProduct(lhs, rhs)
 		qs.setVariable(destName, float64(res))
-		return true
+		return qcContinue
 	})
 
-	q.registerCommand("normvec", func(qs *quest, qt *questTask, args []string) bool {
-		if len(args) < 2 {
-			return questCommandErrorArgCount("normvec", qs, qt, len(args), 2)
-		}
-
+	q.registerCommand("normvec", "<dest:vector> <src:vector>", "Normalizes a vector", func(qs *quest, qt *questTask, args []string) questCommandResult {
 		destName := args[0]
 		lhsVecName := args[1]
 
-		lhs, lhsFound := qs.getVector(lhsVecName)
-
-		if !lhsFound {
-			return questCommandErrorThing("normvec", "vector", qs, qt, lhsVecName)
-		}
+		lhs, _ := qs.getVector(lhsVecName)
 
 		raymath.Vector2Normalize(&lhs)
 		qs.setVector(destName, lhs)
-		return true
+		return qcContinue
 	})
 
-	q.registerCommand("flipvec", func(qs *quest, qt *questTask, args []string) bool {
-		if len(args) < 2 {
-			return questCommandErrorArgCount("flipvec", qs, qt, len(args), 2)
-		}
-
+	q.registerCommand("flipvec", "<dest:vector> <src:vector>", "Negates a vector", func(qs *quest, qt *questTask, args []string) questCommandResult {
 		destName := args[0]
 		lhsVecName := args[1]
 
-		lhs, lhsFound := qs.getVector(lhsVecName)
-
-		if !lhsFound {
-			return questCommandErrorThing("flipvec", "vector", qs, qt, lhsVecName)
-		}
+		lhs, _ := qs.getVector(lhsVecName)
 
 		qs.setVector(destName, rl.NewVector2(lhs.Y, -lhs.X))
-		return true
+		return qcContinue
 	})
 
-	q.registerCommand("lenvec", func(qs *quest, qt *questTask, args []string) bool {
-		if len(args) < 2 {
-			return questCommandErrorArgCount("lenvec", qs, qt, len(args), 2)
-		}
-
+	q.registerCommand("lenvec", "<dest:variable> <src:vector>", "Stores the length of a vector to a variable", func(qs *quest, qt *questTask, args []string) questCommandResult {
 		destName := args[0]
 		lhsVecName := args[1]
 
-		lhs, lhsFound := qs.getVector(lhsVecName)
-
-		if !lhsFound {
-			return questCommandErrorThing("lenvec", "vector", qs, qt, lhsVecName)
-		}
+		lhs, _ := qs.getVector(lhsVecName)
 
 		qs.setVariable(destName, float64(raymath.Vector2Length(lhs)))
-		return true
+		return qcContinue
 	})
 }
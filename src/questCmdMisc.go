@@ -7,48 +7,30 @@ import (
 )
 
 func questInitMiscCommands(q *questManager) {
-	q.registerCommand("say", func(qs *quest, qt *questTask, args []string) bool {
-		if len(args) != 1 {
-			return questCommandErrorArgCount("say", qs, qt, len(args), 1)
-		}
-
-		res, ok := qs.getResource(args[0])
+	q.beginCommandGroup("Miscellaneous")
 
-		if !ok {
-			return questCommandErrorThing("say", "message", qs, qt, args[0])
-		}
+	q.registerCommand("say", "<message:resource>", "Shows a message box", func(qs *quest, qt *questTask, args []string) questCommandResult {
+		res, _ := qs.getResource(args[0])
 
 		qs.printf(qt, "temp saying[%s]: %s", args[0], qs.processText(res.content))
 		PushNotification(qs.processText(res.content), rl.RayWhite)
 
-		return true
+		return qcContinue
 	})
 
-	q.registerCommand("play", func(qs *quest, qt *questTask, args []string) bool {
+	q.registerCommand("play", "<sound:resource>", "Plays a sound from a sequence", func(qs *quest, qt *questTask, args []string) questCommandResult {
 		qs.printf(qt, "playing something")
-		return true
+		return qcContinue
 	})
 
-	q.registerCommand("give", func(qs *quest, qt *questTask, args []string) bool {
-		if len(args) != 2 {
-			return questCommandErrorArgCount("give", qs, qt, len(args), 2)
-		}
-
-		amount, ok := qs.getNumberOrVariable(args[1])
-
-		if !ok {
-			return questCommandErrorArgType("give", qs, qt, args[1], "string", "integer")
-		}
+	q.registerCommand("give", "<item> <amount:number>", "Gives an item of a specified amount", func(qs *quest, qt *questTask, args []string) questCommandResult {
+		amount, _ := qs.getNumberOrVariable(args[1])
 
 		qs.printf(qt, "giving %f of %s", amount, args[0])
-		return true
+		return qcContinue
 	})
 
-	q.registerCommand("log", func(qs *quest, qt *questTask, args []string) bool {
-		if len(args) < 2 {
-			return questCommandErrorArgCount("log", qs, qt, len(args), 2)
-		}
-
+	q.registerCommand("log", "<type> <value:text...>", "Prints a value to the quest's log, `type` is one of `str`, `num` and `vec`", func(qs *quest, qt *questTask, args []string) questCommandResult {
 		logType := args[0]
 
 		switch logType {
@@ -72,6 +54,6 @@ func questInitMiscCommands(q *questManager) {
 			}
 		}
 
-		return true
+		return qcContinue
 	})
 }
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"strings"
+)
+
+const (
+	kwInto     = "into"
+	kwFinished = "finished"
+	kwFailed   = "failed"
+)
+
+func questInitQuestCommands(q *questManager) {
+	q.beginCommandGroup("Quests")
+
+	q.registerCommand("startquest", "<template> [params...]", "Starts a new quest, `param=value` pairs set its parameters and `into variable` stores its ID (`-1` when the quest could not be started). Values are copied from variables of the same name, evaluated as numbers or passed as strings", func(qs *quest, qt *questTask, args []string) questCommandResult {
+		tplName := args[0]
+		params := args[1:]
+		idVar := ""
+
+		if len(params) >= 2 && strings.ToLower(params[len(params)-2]) == kwInto {
+			idVar = params[len(params)-1]
+			params = params[:len(params)-2]
+		}
+
+		details := map[string]questVar{}
+
+		for _, v := range params {
+			kv := strings.SplitN(v, "=", 2)
+
+			if len(kv) != 2 {
+				return questCommandErrorArgType("startquest", qs, qt, v, "string", "param=value")
+			}
+
+			details[kv[0]] = qs.getArgValue(kv[1])
+		}
+
+		ok, reason, id := currentGameMode.quests.addQuest(tplName, details)
+
+		if !ok {
+			qs.printf(qt, "quest '%s' could not be started: %s", tplName, reason)
+		} else {
+			qs.printf(qt, "quest '%s' has been started with ID: %d", tplName, id)
+		}
+
+		if idVar != "" {
+			qs.setVariable(idVar, float64(id))
+		}
+
+		return qcContinue
+	})
+
+	q.registerCommand("send", "<quest> <event> [args...]", "Queues an event for a quest with given ID or all running quests of a template", func(qs *quest, qt *questTask, args []string) questCommandResult {
+		eventArgs := []questVar{}
+
+		for _, v := range args[2:] {
+			eventArgs = append(eventArgs, qs.getArgValue(v))
+		}
+
+		if id, ok := qs.getNumberOrVariable(args[0]); ok {
+			currentGameMode.quests.callEvent(int64(id), args[1], eventArgs)
+			qs.printf(qt, "event '%s' was sent to quest: %d", args[1], int64(id))
+		} else {
+			currentGameMode.quests.callTemplateEvent(args[0], args[1], eventArgs)
+			qs.printf(qt, "event '%s' was sent to quests: '%s'", args[1], args[0])
+		}
+
+		return qcContinue
+	})
+
+	q.registerCommand("waitquest", "<quest:number> <state>", "Blocks execution until the quest reaches a given state, `finished` or `failed`, fails when the quest ends otherwise or can't be found", func(qs *quest, qt *questTask, args []string) questCommandResult {
+		id, _ := qs.getNumberOrVariable(args[0])
+
+		var state int
+
+		switch strings.ToLower(args[1]) {
+		case kwFinished:
+			state = qsFinished
+		case kwFailed:
+			state = qsFailed
+		default:
+			return questCommandErrorArgType("waitquest", qs, qt, args[1], args[1], "finished|failed")
+		}
+
+		other := currentGameMode.quests.findQuest(int64(id))
+
+		if other == nil {
+			return questCommandErrorThing("waitquest", "quest", qs, qt, args[0])
+		}
+
+		// an abandoned quest or one ending in the other state is never going to get there
+		if other.state != qsInProgress && other.state != state {
+			return questCommandErrorQuestEnded("waitquest", qs, qt, other.name, questStateNames[other.state])
+		}
+
+		done := other.state == state
+
+		if done {
+			qs.printf(qt, "quest '%s' has reached state '%s'!", other.name, args[1])
+		}
+
+		return questWaitFor(done)
+	})
+}
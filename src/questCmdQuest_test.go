@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestWaitQuestEndStates(t *testing.T) {
+	withQuestFiles(t, map[string]string{
+		"quests/waiter.qst": "TITLE: Waiter\n\nQST:\n\nwait 1000\n",
+		"quests/target.qst": "TITLE: Target\n\nQST:\n\nwait 1000\n",
+	})
+
+	q := &currentGameMode.quests
+	_, _, waiterID := q.addQuest("waiter", nil)
+	_, _, targetID := q.addQuest("target", nil)
+	target := fmt.Sprint(targetID)
+
+	tests := []struct {
+		name  string
+		state int
+		await string
+		res   questCommandResult
+		code  int
+	}{
+		{"in progress", qsInProgress, "finished", qcBlock, 0},
+		{"finished", qsFinished, "finished", qcContinue, 0},
+		{"failed", qsFailed, "failed", qcContinue, 0},
+		{"failed while awaiting finished", qsFailed, "finished", qcError, qeQuestEnded},
+		{"abandoned", qsAbandoned, "finished", qcError, qeQuestEnded},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			q.findQuest(targetID).state = tc.state
+			qs := q.findQuest(waiterID)
+			qs.lastError = questError{}
+
+			res := q.runCommand(qs, &qs.tasks[0], "waitquest", []string{target, tc.await})
+
+			if res != tc.res || qs.lastError.code != tc.code {
+				t.Errorf("got result %d with error %d, want %d with error %d", res, qs.lastError.code, tc.res, tc.code)
+			}
+		})
+	}
+
+	qs := q.findQuest(waiterID)
+
+	if res := q.runCommand(qs, &qs.tasks[0], "waitquest", []string{"12345", "finished"}); res != qcError || qs.lastError.code != qeNotFound {
+		t.Errorf("got result %d with error %d for a missing quest, want an error %d", res, qs.lastError.code, qeNotFound)
+	}
+}
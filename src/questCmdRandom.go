@@ -0,0 +1,54 @@
+package main
+
+import (
+	"math"
+)
+
+func questInitRandomCommands(q *questManager) {
+	q.beginCommandGroup("Random")
+
+	q.registerCommand("randint", "<dest:variable> <min:number> <max:number>", "Stores a random whole number between `min` and `max` to a variable, both included", func(qs *quest, qt *questTask, args []string) questCommandResult {
+		min, _ := qs.getNumberOrVariable(args[1])
+		max, _ := qs.getNumberOrVariable(args[2])
+
+		lo, hi := int64(math.Ceil(min)), int64(math.Floor(max))
+
+		if lo > hi {
+			return questCommandError(qeArgType, "randint", qs, qt, "range '%s' to '%s' is empty", args[1], args[2])
+		}
+
+		val := lo + qs.rand().Int63n(hi-lo+1)
+		qs.setVariable(args[0], float64(val))
+
+		qs.printf(qt, "variable '%s' was set to: %d", args[0], val)
+		return qcContinue
+	})
+
+	q.registerCommand("chance", "<percent:number>", "Continues with the given chance in percent, otherwise skips the next command", func(qs *quest, qt *questTask, args []string) questCommandResult {
+		pct, _ := qs.getNumberOrVariable(args[0])
+
+		if qs.rand().Float64()*100 >= pct {
+			qs.printf(qt, "chance of %.1f%% has failed, skipping the next command", pct)
+			qt.pc++
+		}
+
+		return qcContinue
+	})
+
+	q.registerCommand("pick", "<dest:variable> <values...>", "Stores one of the values picked at random to a variable. Values are copied from variables of the same name, evaluated as numbers or stored as strings", func(qs *quest, qt *questTask, args []string) questCommandResult {
+		arg := args[1+qs.rand().Intn(len(args)-1)]
+		val := qs.getArgValue(arg)
+
+		switch v := val.value.(type) {
+		case *questVarNumber:
+			qs.setVariable(args[0], v.value)
+		case *questVarVector:
+			qs.setVector(args[0], v.value)
+		default:
+			qs.setString(args[0], val.value.str())
+		}
+
+		qs.printf(qt, "variable '%s' was set to: '%s'", args[0], val.value.str())
+		return qcContinue
+	})
+}
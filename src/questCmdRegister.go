@@ -4,4 +4,6 @@ func questInitCommands(q *questManager) {
 	questInitEntityCommands(q)
 	questInitMiscCommands(q)
 	questInitMathCommands(q)
+	questInitRandomCommands(q)
+	questInitQuestCommands(q)
 }
@@ -1,50 +1,45 @@
 package main
 
 import (
+	"strings"
+
 	"github.com/zaklaus/rurik/src/core"
 )
 
 func questInitBaseCommands(q *questManager) {
-	q.registerCommand("variable", func(qs *quest, qt *questTask, args []string) bool {
-		if len(args) != 1 {
-			return questCommandErrorArgCount("variable", qs, qt, len(args), 1)
-		}
+	q.beginCommandGroup("Variables")
 
+	q.registerCommand("variable", "<name:variable>", "Declares a new variable", func(qs *quest, qt *questTask, args []string) questCommandResult {
 		qs.setVariable(args[0], 0)
 
 		qs.printf(qt, "variable '%s' was declared", args[0])
 
-		return true
+		return qcContinue
 	})
 
-	q.registerCommand("setvar", func(qs *quest, qt *questTask, args []string) bool {
-		if len(args) != 2 {
-			return questCommandErrorArgCount("setvar", qs, qt, len(args), 2)
-		}
-
-		val, ok := qs.getNumberOrVariable(args[1])
-
-		if !ok {
-			return questCommandErrorArgType("setvar", qs, qt, args[1], "string", "integer")
-		}
+	q.registerCommand("setvar", "<name:variable> <value:number>", "Sets a value to a variable", func(qs *quest, qt *questTask, args []string) questCommandResult {
+		val, _ := qs.getNumberOrVariable(args[1])
 
 		qs.setVariable(args[0], val)
 
 		qs.printf(qt, "variable '%s' was set to: %f", args[0], val)
 
-		return true
+		return qcContinue
 	})
 
-	q.registerCommand("timer", func(qs *quest, qt *questTask, args []string) bool {
-		if len(args) != 2 {
-			return questCommandErrorArgCount("timer", qs, qt, len(args), 2)
-		}
+	q.registerCommand("setstr", "<name:variable> <text:text...>", "Sets a string to a variable, `%var%` placeholders in the text are substituted", func(qs *quest, qt *questTask, args []string) questCommandResult {
+		val := qs.processText(strings.Join(args[1:], " "))
+		qs.setString(args[0], val)
 
-		duration, ok := qs.getNumberOrVariable(args[1])
+		qs.printf(qt, "variable '%s' was set to: '%s'", args[0], val)
 
-		if !ok {
-			return questCommandErrorArgType("timer", qs, qt, args[1], "string", "integer")
-		}
+		return qcContinue
+	})
+
+	q.beginCommandGroup("Timers")
+
+	q.registerCommand("timer", "<name:timer> <duration:number>", "Sets up a new timer with a specified duration", func(qs *quest, qt *questTask, args []string) questCommandResult {
+		duration, _ := qs.getNumberOrVariable(args[1])
 
 		qs.timers[args[0]] = questTimer{
 			time:     -1,
@@ -53,157 +48,240 @@ func questInitBaseCommands(q *questManager) {
 
 		qs.printf(qt, "timer '%s' was declared with duration: %f", args[0], duration)
 
-		return true
+		return qcContinue
 	})
 
-	q.registerCommand("stage", func(qs *quest, qt *questTask, args []string) bool {
-		if len(args) != 1 {
-			return questCommandErrorArgCount("stage", qs, qt, len(args), 1)
-		}
+	q.beginCommandGroup("Stages")
 
-		res, ok := qs.getResource(args[0])
+	q.registerCommand("stage", "<stage:resource> [target:number]", "Adds a new stage to the quest's journal, the optional target overrides the one set by the resource", func(qs *quest, qt *questTask, args []string) questCommandResult {
+		res, _ := qs.getResource(args[0])
 
-		if !ok {
+		if res.kind != qrStage {
 			return questCommandErrorThing("stage", "resource", qs, qt, args[0])
 		}
 
-		stageID := atoiUnsafe(args[0])
+		var target float64
 
-		qs.stages[stageID] = questStage{
-			step:  res.content,
-			state: qsInProgress,
+		if len(args) == 2 {
+			target, _ = qs.getNumberOrVariable(args[1])
 		}
 
+		stageID, ok := qs.resourceID(args[0])
+
+		if !ok {
+			return questCommandErrorThing("stage", "stage", qs, qt, args[0])
+		}
+
+		qs.addStage(stageID, res, int(target))
+
 		qs.printf(qt, "stage '%d' has been added!", stageID)
 
-		return true
+		return qcContinue
 	})
 
-	q.registerCommand("stdone", func(qs *quest, qt *questTask, args []string) bool {
-		if len(args) != 1 {
-			return questCommandErrorArgCount("stdone", qs, qt, len(args), 1)
+	q.registerCommand("stprogress", "<stage:resource> <amount:number>", "Advances the stage's progress counter, the stage is completed once it reaches its target", func(qs *quest, qt *questTask, args []string) questCommandResult {
+		amount, _ := qs.getNumberOrVariable(args[1])
+
+		stageID, ok := qs.resourceID(args[0])
+
+		if !ok {
+			return questCommandErrorThing("stprogress", "stage", qs, qt, args[0])
+		}
+
+		if !qs.progressStage(stageID, int(amount)) {
+			return questCommandErrorThing("stprogress", "stage", qs, qt, args[0])
 		}
 
-		stageID := atoiUnsafe(args[0])
-		sta, ok := qs.stages[stageID]
+		qs.printf(qt, "stage '%d' has progressed by %d!", stageID, int(amount))
+
+		return qcContinue
+	})
+
+	q.registerCommand("streveal", "<stage:resource>", "Reveals a hidden stage", func(qs *quest, qt *questTask, args []string) questCommandResult {
+		stageID, ok := qs.resourceID(args[0])
 
 		if !ok {
-			return questCommandErrorThing("stdone", "resource", qs, qt, args[0])
+			return questCommandErrorThing("streveal", "stage", qs, qt, args[0])
 		}
 
-		qs.printf(qt, "stage '%d' has succeeded!", stageID)
+		if !qs.revealStage(stageID) {
+			return questCommandErrorThing("streveal", "stage", qs, qt, args[0])
+		}
 
-		sta.state = qsFinished
-		qs.stages[stageID] = sta
+		qs.printf(qt, "stage '%d' has been revealed!", stageID)
 
-		return true
+		return qcContinue
 	})
 
-	q.registerCommand("stfail", func(qs *quest, qt *questTask, args []string) bool {
-		if len(args) != 1 {
-			return questCommandErrorArgCount("stfail", qs, qt, len(args), 1)
+	q.registerCommand("stdone", "<stage:resource>", "Marks the stage as completed successfully", func(qs *quest, qt *questTask, args []string) questCommandResult {
+		stageID, ok := qs.resourceID(args[0])
+
+		if !ok {
+			return questCommandErrorThing("stdone", "stage", qs, qt, args[0])
 		}
 
-		stageID := atoiUnsafe(args[0])
-		sta, ok := qs.stages[stageID]
+		if !qs.setStageState(stageID, qsFinished) {
+			return questCommandErrorThing("stdone", "resource", qs, qt, args[0])
+		}
+
+		qs.printf(qt, "stage '%d' has succeeded!", stageID)
+
+		return qcContinue
+	})
+
+	q.registerCommand("stfail", "<stage:resource>", "Marks the stage as failed", func(qs *quest, qt *questTask, args []string) questCommandResult {
+		stageID, ok := qs.resourceID(args[0])
 
 		if !ok {
+			return questCommandErrorThing("stfail", "stage", qs, qt, args[0])
+		}
+
+		if !qs.setStageState(stageID, qsFailed) {
 			return questCommandErrorThing("stfail", "resource", qs, qt, args[0])
 		}
 
 		qs.printf(qt, "stage '%d' has failed!", stageID)
 
-		sta.state = qsFailed
-		qs.stages[stageID] = sta
-
-		return true
+		return qcContinue
 	})
 
-	q.registerCommand("repeat", func(qs *quest, qt *questTask, args []string) bool {
+	q.beginCommandGroup("Flow control")
+
+	q.registerCommand("repeat", "", "Repeats the task", func(qs *quest, qt *questTask, args []string) questCommandResult {
 		qt.pc = -1
 
 		qs.printf(qt, "repeating task '%s'!", qt.name)
 
-		return true
+		return qcContinue
 	})
 
-	q.registerCommand("fire", func(qs *quest, qt *questTask, args []string) bool {
-		if len(args) != 1 {
-			return questCommandErrorArgCount("fire", qs, qt, len(args), 1)
+	q.registerCommand("start", "<task:task>", "Starts a dormant task", func(qs *quest, qt *questTask, args []string) questCommandResult {
+		task := qs.findTask(args[0])
+
+		if task == nil || task.isEvent {
+			return questCommandErrorThing("start", "task", qs, qt, args[0])
 		}
 
-		tm, ok := qs.timers[args[0]]
+		task.isDormant = false
 
-		if !ok {
-			return questCommandErrorThing("fire", "timer", qs, qt, args[0])
+		qs.printf(qt, "task '%s' was started!", args[0])
+
+		return qcContinue
+	})
+
+	q.registerCommand("stoptask", "<task:task>", "Stops a task, making it dormant", func(qs *quest, qt *questTask, args []string) questCommandResult {
+		task := qs.findTask(args[0])
+
+		if task == nil || task.isEvent {
+			return questCommandErrorThing("stoptask", "task", qs, qt, args[0])
 		}
 
-		qs.printf(qt, "timer '%s' was fired!", args[0])
-		tm.time = tm.duration
-		qs.timers[args[0]] = tm
+		task.isDormant = true
+
+		qs.printf(qt, "task '%s' was stopped!", args[0])
 
-		return true
+		return qcContinue
 	})
 
-	q.registerCommand("stop", func(qs *quest, qt *questTask, args []string) bool {
-		if len(args) != 1 {
-			return questCommandErrorArgCount("stop", qs, qt, len(args), 1)
+	q.registerCommand("restart", "<task:task>", "Starts a task over from the beginning", func(qs *quest, qt *questTask, args []string) questCommandResult {
+		task := qs.findTask(args[0])
+
+		if task == nil || task.isEvent {
+			return questCommandErrorThing("restart", "task", qs, qt, args[0])
 		}
 
-		tm, ok := qs.timers[args[0]]
+		task.isDormant = false
+		task.isDone = false
+		task.waitTime = 0
 
-		if !ok {
-			return questCommandErrorThing("stop", "timer", qs, qt, args[0])
+		if task == qt {
+			// compensate for the pc increment after the command
+			task.pc = -1
+		} else {
+			task.pc = 0
 		}
 
-		qs.printf(qt, "timer '%s' was stopped!", args[0])
-		tm.time = -1
-		qs.timers[args[0]] = tm
+		qs.printf(qt, "task '%s' was restarted!", args[0])
 
-		return true
+		return qcContinue
 	})
 
-	q.registerCommand("done", func(qs *quest, qt *questTask, args []string) bool {
-		if len(args) != 1 {
-			return questCommandErrorArgCount("done", qs, qt, len(args), 1)
-		}
+	q.registerCommand("wait", "<duration:number>", "Blocks execution for a specified number of seconds, no timer has to be declared", func(qs *quest, qt *questTask, args []string) questCommandResult {
+		duration, _ := qs.getNumberOrVariable(args[0])
+
+		return questWaitFor(qt.waitTime >= float32(duration))
+	})
 
-		tm, ok := qs.timers[args[0]]
+	q.registerCommand("label", "<name:label>", "Marks a place in the task to jump to", func(qs *quest, qt *questTask, args []string) questCommandResult {
+		return qcContinue
+	})
 
-		if !ok {
-			return questCommandErrorThing("done", "timer", qs, qt, args[0])
+	q.registerCommand("goto", "<target:label>", "Continues past a label in the current task, or ends the current task and starts a task of such name", func(qs *quest, qt *questTask, args []string) questCommandResult {
+		if !qs.jump(qt, args[0]) {
+			return questCommandErrorThing("goto", "label or task", qs, qt, args[0])
 		}
 
+		// compensate for the pc increment after the command
+		qt.pc--
+
+		return qcContinue
+	})
+
+	q.beginCommandGroup("Timers")
+
+	q.registerCommand("fire", "<timer:timer>", "Fires a timer. It sets the remaining time to the initial timer's duration", func(qs *quest, qt *questTask, args []string) questCommandResult {
+		tm := qs.timers[args[0]]
+
+		qs.printf(qt, "timer '%s' was fired!", args[0])
+		tm.time = tm.duration
+		qs.timers[args[0]] = tm
+
+		return qcContinue
+	})
+
+	q.registerCommand("stop", "<timer:timer>", "Interrupts a timer", func(qs *quest, qt *questTask, args []string) questCommandResult {
+		tm := qs.timers[args[0]]
+
+		qs.printf(qt, "timer '%s' was stopped!", args[0])
+		tm.time = -1
+		qs.timers[args[0]] = tm
+
+		return qcContinue
+	})
+
+	q.registerCommand("done", "<timer:timer>", "Checks whether the timer is already expired, blocks execution if not", func(qs *quest, qt *questTask, args []string) questCommandResult {
+		tm := qs.timers[args[0]]
 		state := tm.time == 0
 
 		if state {
 			qs.printf(qt, "timer '%s' is done!", args[0])
 		}
 
-		return state
+		return questWaitFor(state)
 	})
 
-	q.registerCommand("finish", func(qs *quest, qt *questTask, args []string) bool {
-		qs.state = qsFinished
+	q.beginCommandGroup("Flow control")
+
+	q.registerCommand("finish", "", "Marks the quest as completed (This ends the quest)", func(qs *quest, qt *questTask, args []string) questCommandResult {
+		qs.setState(qsFinished)
+		qs.finishedAt = currentGameMode.quests.time
 
 		qs.printf(qt, "quest '%s' has been finished!", qs.name)
 
-		return true
+		return qcContinue
 	})
 
-	q.registerCommand("fail", func(qs *quest, qt *questTask, args []string) bool {
-		qs.state = qsFailed
+	q.registerCommand("fail", "", "Marks the quest as failed (This ends the quest)", func(qs *quest, qt *questTask, args []string) questCommandResult {
+		qs.setState(qsFailed)
 
 		qs.printf(qt, "quest '%s' has been failed!", qs.name)
 
-		return true
+		return qcContinue
 	})
 
-	q.registerCommand("pop", func(qs *quest, qt *questTask, args []string) bool {
-		if len(args) != 1 {
-			return questCommandErrorArgCount("pop", qs, qt, len(args), 1)
-		}
+	q.beginCommandGroup("Events")
 
+	q.registerCommand("pop", "<name:variable>", "Pops a value from a stack and stores it to a variable", func(qs *quest, qt *questTask, args []string) questCommandResult {
 		if len(qt.eventArgs) == 0 {
 			return questCommandErrorEventArgsEmpty("pop", qs, qt)
 		}
@@ -211,61 +289,58 @@ func questInitBaseCommands(q *questManager) {
 		val := qt.eventArgs[0]
 		qt.eventArgs = qt.eventArgs[1:]
 
-		qs.setVariable(args[0], val)
+		qs.setAnyVariable(args[0], val)
 
-		qs.printf(qt, "event pop value '%f' for: '%s'", val, args[0])
+		qs.printf(qt, "event pop value '%s' for: '%s'", val.value.str(), args[0])
 
-		return true
+		return qcContinue
 	})
 
-	q.registerCommand("when", func(qs *quest, qt *questTask, args []string) bool {
-		if len(args) < 1 {
-			return questCommandErrorArgCount("when", qs, qt, len(args), 1)
-		}
+	q.beginCommandGroup("Flow control")
 
-		lhs, ok := qs.getNumberOrVariable(args[0])
-
-		if !ok {
-			return questCommandErrorArgType("when", qs, qt, args[0], "string", "integer")
-		}
+	q.registerCommand("when", "<lhs:number> [op] [rhs:number]", "Checks a condition and decides whether to pause the task execution or continue, `op` is one of `above`, `below`, `equals`, `!equals`, `and`, `or` and `xor`", func(qs *quest, qt *questTask, args []string) questCommandResult {
+		lhs, _ := qs.getNumberOrVariable(args[0])
 
 		if len(args) == 1 {
-			return lhs > 0
+			return questWaitFor(lhs > 0)
 		}
 
-		rhs, ok2 := qs.getNumberOrVariable(args[2])
-
-		if !ok2 {
-			return questCommandErrorArgType("when", qs, qt, args[2], "string", "integer")
+		// the operator and its right-hand side come in pairs
+		if len(args) == 2 {
+			return questCommandErrorArgCount("when", qs, qt, len(args), 3)
 		}
 
+		rhs, _ := qs.getNumberOrVariable(args[2])
+
 		switch args[1] {
 		case kwBelow:
-			return lhs < rhs
+			return questWaitFor(lhs < rhs)
 		case kwAbove:
-			return lhs > rhs
+			return questWaitFor(lhs > rhs)
 		case kwEquals:
-			return lhs == rhs
+			return questWaitFor(lhs == rhs)
 		case kwNotEquals:
-			return lhs != rhs
+			return questWaitFor(lhs != rhs)
 		case kwAnd:
-			return (lhs != 0) && (rhs != 0)
+			return questWaitFor((lhs != 0) && (rhs != 0))
 		case kwOr:
-			return (lhs != 0) || (rhs != 0)
+			return questWaitFor((lhs != 0) || (rhs != 0))
 		case kwXor:
-			return ((lhs != 0) || (rhs != 0)) && !((lhs != 0) && (rhs != 0))
+			return questWaitFor(((lhs != 0) || (rhs != 0)) && !((lhs != 0) && (rhs != 0)))
 		default:
-			return questCommandErrorArgComp("when", qs, qt, args[2])
+			return questCommandErrorArgComp("when", qs, qt, args[1])
 		}
 	})
 
-	q.registerCommand("invoke", func(qs *quest, qt *questTask, args []string) bool {
-		if len(args) < 1 {
-			return questCommandErrorArgCount("invoke", qs, qt, len(args), 1)
+	q.beginCommandGroup("Events")
+
+	q.registerCommand("invoke", "<event> [args...]", "Fires a core event from the allow-list with the given arguments", func(qs *quest, qt *questTask, args []string) questCommandResult {
+		if msg := checkInvoke(args, qs.permission); msg != "" {
+			return questCommandErrorNotAllowed("invoke", qs, qt, msg)
 		}
 
 		core.FireEvent(args[0], args[1:])
-		return true
+		return qcContinue
 	})
 
 	// game-specific register
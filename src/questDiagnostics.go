@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	rl "github.com/zaklaus/raylib-go/raylib"
+	"github.com/zaklaus/rurik/src/system"
+)
+
+const (
+	// questCommandBudget is the number of commands a task can execute within a single frame
+	questCommandBudget = 256
+
+	// questRunawayFrames is the number of consecutive frames a task can use up its budget
+	// before it's considered to be stuck in an infinite loop
+	questRunawayFrames = 60
+
+	maxQuestDiagnostics = 32
+	questDiagnosticsY   = 60
+)
+
+type questDiagnostic struct {
+	questID   int64
+	questName string
+	taskName  string
+	message   string
+}
+
+func (d questDiagnostic) String() string {
+	return fmt.Sprintf("%s(%d):%s: %s", d.questName, d.questID, d.taskName, d.message)
+}
+
+// report stores a diagnostic shown in the debug overlay
+func (q *questManager) report(qs *quest, qt *questTask, format string, args ...interface{}) {
+	diag := questDiagnostic{
+		questID:   qs.ID,
+		questName: qs.name,
+		message:   fmt.Sprintf(format, args...),
+	}
+
+	if qt != nil {
+		diag.taskName = qt.name
+	}
+
+	log.Printf("Quest '%s':'%s': %s", diag.questName, diag.taskName, diag.message)
+
+	q.diagnostics = append(q.diagnostics, diag)
+
+	if len(q.diagnostics) > maxQuestDiagnostics {
+		q.diagnostics = q.diagnostics[len(q.diagnostics)-maxQuestDiagnostics:]
+	}
+}
+
+// resetBudget gives each task of the quest its command budget for the frame
+func (qs *quest) resetBudget() {
+	for i := range qs.tasks {
+		qs.tasks[i].budget = questCommandBudget
+		qs.tasks[i].budgetExhausted = false
+	}
+}
+
+// checkBudget looks for tasks that keep using up their command budget
+func (qs *quest) checkBudget(q *questManager) {
+	for i := range qs.tasks {
+		qt := &qs.tasks[i]
+
+		if !qt.budgetExhausted {
+			qt.runawayFrames = 0
+			continue
+		}
+
+		qt.runawayFrames++
+
+		if qt.runawayFrames != questRunawayFrames {
+			continue
+		}
+
+		q.report(qs, qt, "task runs away, it executed %d commands per frame for %d frames",
+			questCommandBudget, questRunawayFrames)
+
+		if qs.failOnRunaway && qs.state == qsInProgress {
+			q.report(qs, qt, "quest has been failed due to a runaway task")
+			qs.setState(qsFailed)
+		}
+	}
+}
+
+func drawQuestDiagnostics() {
+	diags := currentGameMode.quests.diagnostics
+
+	if len(diags) == 0 {
+		return
+	}
+
+	y := int32(questDiagnosticsY)
+	rl.DrawText("Quest diagnostics:", 5, y, 10, rl.Orange)
+
+	for i := len(diags) - 1; i >= 0; i-- {
+		y += 12
+
+		if y > system.ScreenHeight-12 {
+			break
+		}
+
+		rl.DrawText(diags[i].String(), 5, y, 10, rl.RayWhite)
+	}
+}
@@ -5,36 +5,76 @@ import (
 	"log"
 )
 
+// Quest error codes, passed to the ON_ERROR task
+const (
+	qeArgCount = iota + 1
+	qeDivideByZero
+	qeNotFound
+	qeArgType
+	qeArgComp
+	qeEventArgsEmpty
+	qeUnknownCommand
+	qeNotAllowed
+	qeQuestEnded
+)
+
+type questError struct {
+	code    int
+	message string
+}
+
 func questCommandErrorBase(cmd string, qs *quest, qt *questTask) string {
 	return fmt.Sprintf("Command '%s' failed at quest '%s':'%s'(%d): ", cmd, qs.name, qt.name, qt.pc)
 }
 
-func questCommandErrorArgCount(cmd string, qs *quest, qt *questTask, has, need int) bool {
-	log.Printf("%s needs '%d' arguments, got: '%d'", questCommandErrorBase(cmd, qs, qt), need, has)
-	return false
+func questCommandError(code int, cmd string, qs *quest, qt *questTask, format string, args ...interface{}) questCommandResult {
+	msg := fmt.Sprintf(format, args...)
+	log.Printf("%s %s", questCommandErrorBase(cmd, qs, qt), msg)
+
+	qs.lastError = questError{
+		code:    code,
+		message: fmt.Sprintf("command '%s' %s", cmd, msg),
+	}
+
+	return qcError
+}
+
+func questCommandErrorArgCount(cmd string, qs *quest, qt *questTask, has, need int) questCommandResult {
+	return questCommandError(qeArgCount, cmd, qs, qt, "needs '%d' arguments, got: '%d'", need, has)
+}
+
+func questCommandErrorArgRange(cmd string, qs *quest, qt *questTask, has int, need string) questCommandResult {
+	return questCommandError(qeArgCount, cmd, qs, qt, "needs %s arguments, got: '%d'", need, has)
+}
+
+func questCommandErrorDivideByZero(cmd string, qs *quest, qt *questTask) questCommandResult {
+	return questCommandError(qeDivideByZero, cmd, qs, qt, "division by zero")
+}
+
+func questCommandErrorThing(cmd, thing string, qs *quest, qt *questTask, resName string) questCommandResult {
+	return questCommandError(qeNotFound, cmd, qs, qt, "%s '%s' could not be found", thing, resName)
+}
+
+func questCommandErrorArgType(cmd string, qs *quest, qt *questTask, argName, has, need string) questCommandResult {
+	return questCommandError(qeArgType, cmd, qs, qt, "argument '%s' has to be '%s', got: '%s'", argName, need, has)
 }
 
-func questCommandErrorDivideByZero(cmd string, qs *quest, qt *questTask) bool {
-	log.Printf("%s division by zero", questCommandErrorBase(cmd, qs, qt))
-	return false
+func questCommandErrorArgComp(cmd string, qs *quest, qt *questTask, argName string) questCommandResult {
+	return questCommandError(qeArgComp, cmd, qs, qt, "argument has to be either 'above,below,equals,!equals', got: '%s'", argName)
 }
 
-func questCommandErrorThing(cmd, thing string, qs *quest, qt *questTask, resName string) bool {
-	log.Printf("%s %s '%s' could not be found", questCommandErrorBase(cmd, qs, qt), thing, resName)
-	return false
+func questCommandErrorEventArgsEmpty(cmd string, qs *quest, qt *questTask) questCommandResult {
+	return questCommandError(qeEventArgsEmpty, cmd, qs, qt, "event's arg stack is already empty!")
 }
 
-func questCommandErrorArgType(cmd string, qs *quest, qt *questTask, argName, has, need string) bool {
-	log.Printf("%s argument '%s' has to be '%s', got: '%s'", questCommandErrorBase(cmd, qs, qt), argName, need, has)
-	return false
+func questCommandErrorUnknown(cmd string, qs *quest, qt *questTask) questCommandResult {
+	return questCommandError(qeUnknownCommand, cmd, qs, qt, "is not recognized")
 }
 
-func questCommandErrorArgComp(cmd string, qs *quest, qt *questTask, argName string) bool {
-	log.Printf("%s argument has to be either 'above,below,equals,!equals', got: '%s'", questCommandErrorBase(cmd, qs, qt), argName)
-	return false
+func questCommandErrorNotAllowed(cmd string, qs *quest, qt *questTask, reason string) questCommandResult {
+	return questCommandError(qeNotAllowed, cmd, qs, qt, "is not allowed: %s", reason)
 }
 
-func questCommandErrorEventArgsEmpty(cmd string, qs *quest, qt *questTask) bool {
-	log.Printf("%s event's arg stack is already empty!", questCommandErrorBase(cmd, qs, qt))
-	return false
+func questCommandErrorQuestEnded(cmd string, qs *quest, qt *questTask, name, state string) questCommandResult {
+	return questCommandError(qeQuestEnded, cmd, qs, qt, "quest '%s' has ended as '%s'", name, state)
 }
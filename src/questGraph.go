@@ -0,0 +1,439 @@
+package main
+
+/*
+	Quest flow graph
+
+	Usage: game.exe -questgraph [-format dot|mermaid] [-progress file] [-o file] quest.qst
+
+	Tasks and events become nodes, edges are inferred from the commands:
+	- 'when <task>' waits for a task to be done
+	- timers fired in one task and awaited by 'done' in another
+	- tasks started, stopped, restarted or jumped to, including timeouts
+	- 'repeat' loops and the order of tasks in sequential quests
+	- 'invoke', 'startquest' and 'send' reaching outside of the quest
+	- 'finish' and 'fail' ending the quest
+
+	The progress written along with the save marks tasks the player has already done.
+*/
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	nodeEntry = iota
+	nodeTask
+	nodeEvent
+	nodeExternal
+	nodeExit
+)
+
+const (
+	progressUnknown = iota
+	progressPending
+	progressDone
+	progressDormant
+)
+
+type questGraphNode struct {
+	id       string
+	label    string
+	kind     int
+	dormant  bool
+	progress int
+}
+
+type questGraphEdge struct {
+	from   string
+	to     string
+	label  string
+	dashed bool
+}
+
+type questGraph struct {
+	name  string
+	nodes []*questGraphNode
+	edges []questGraphEdge
+
+	byName map[string]*questGraphNode
+	seen   map[questGraphEdge]bool
+}
+
+// runQuestGraph handles the '-questgraph' mode, args are the remaining command line arguments
+func runQuestGraph(args []string) {
+	fs := flag.NewFlagSet("questgraph", flag.ExitOnError)
+	format := fs.String("format", "dot", "output format, 'dot' or 'mermaid'")
+	progressFile := fs.String("progress", "", "quest progress written by the game started with '-questprogress'")
+	outFile := fs.String("o", "", "output file, standard output when empty")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s -questgraph [-format dot|mermaid] [-progress file] [-o file] quest.qst\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	write, ok := map[string]func(*questGraph, io.Writer){
+		"dot":     (*questGraph).writeDOT,
+		"mermaid": (*questGraph).writeMermaid,
+	}[*format]
+
+	if !ok {
+		log.Fatalf("Format '%s' is not supported!\n", *format)
+	}
+
+	src := fs.Arg(0)
+	data, err := ioutil.ReadFile(src)
+
+	if err != nil {
+		log.Fatalf("File '%s' could not be read: %s\n", src, err)
+	}
+
+	// libraries are looked up next to the quest, parser logs would only clutter the output
+	root := filepath.Dir(filepath.Dir(src))
+	log.SetOutput(ioutil.Discard)
+	idx := analyzeQuest(src, data, func(name string) []byte {
+		res, _ := ioutil.ReadFile(filepath.Join(root, name))
+		return res
+	})
+	log.SetOutput(os.Stderr)
+
+	for _, v := range idx.diagnostics {
+		log.Printf("%s:%d: %s\n", v.file, v.line, v.message)
+	}
+
+	name := strings.TrimSuffix(filepath.Base(src), filepath.Ext(src))
+	g := buildQuestGraph(name, idx.def)
+
+	if *progressFile != "" {
+		progress, err := loadQuestProgress(*progressFile)
+
+		if err != nil {
+			log.Fatalf("Progress '%s' could not be loaded: %s\n", *progressFile, err)
+		}
+
+		if qs := progress.find(name); qs != nil {
+			g.applyProgress(qs)
+		} else {
+			log.Printf("Progress '%s' has no record of quest '%s'!\n", *progressFile, name)
+		}
+	}
+
+	out := os.Stdout
+
+	if *outFile != "" {
+		f, err := os.Create(*outFile)
+
+		if err != nil {
+			log.Fatalf("Output '%s' could not be created: %s\n", *outFile, err)
+		}
+
+		defer f.Close()
+		out = f
+	}
+
+	write(g, out)
+}
+
+// buildQuestGraph infers the flow between tasks of a parsed quest
+func buildQuestGraph(name string, def *questDef) *questGraph {
+	g := &questGraph{
+		name:   name,
+		byName: map[string]*questGraphNode{},
+		seen:   map[questGraphEdge]bool{},
+	}
+
+	for i, t := range def.taskDef {
+		n := g.node(t.name, t.name, nodeTask)
+		n.dormant = t.isDormant
+
+		switch {
+		case i == 0:
+			n.kind = nodeEntry
+			n.label = "start"
+		case t.isEvent:
+			n.kind = nodeEvent
+		}
+	}
+
+	fired := map[string][]string{}
+	awaited := map[string][]string{}
+	var previous string
+	isFirst := true
+
+	for i, t := range def.taskDef {
+		// sequential quests start the next task once the previous one is done
+		if def.sequential && !t.isEvent && t.name != questErrorHandler {
+			if i > 0 {
+				// the first task starts along with the entry point, the ones after it wait
+				if !isFirst {
+					g.byName[t.name].dormant = true
+				}
+
+				isFirst = false
+				g.edge(previous, t.name, "then", false)
+			}
+
+			previous = t.name
+		}
+
+		for _, c := range t.commands {
+			g.command(t.name, c, fired, awaited)
+		}
+	}
+
+	timers := []string{}
+
+	for k := range awaited {
+		timers = append(timers, k)
+	}
+
+	sort.Strings(timers)
+
+	for _, tm := range timers {
+		for _, from := range fired[tm] {
+			for _, to := range awaited[tm] {
+				// a task waiting for its own timer doesn't add anything to the flow
+				if from != to {
+					g.edge(from, to, "timer "+tm, false)
+				}
+			}
+		}
+	}
+
+	return g
+}
+
+// command adds edges of a single command of the given task
+func (g *questGraph) command(task string, c questCmd, fired, awaited map[string][]string) {
+	arg := func(n int) string {
+		if n < len(c.args) {
+			return c.args[n]
+		}
+
+		return ""
+	}
+
+	isTask := func(name string) bool {
+		n, ok := g.byName[name]
+		return ok && n.kind != nodeExternal && n.kind != nodeExit
+	}
+
+	switch c.name {
+	case "when":
+		for _, v := range []string{arg(0), arg(2)} {
+			if isTask(v) {
+				g.edge(v, task, "when", false)
+			}
+		}
+	case "start", "restart", "goto":
+		if isTask(arg(0)) {
+			g.edge(task, arg(0), c.name, false)
+		}
+	case "stoptask":
+		if isTask(arg(0)) {
+			g.edge(task, arg(0), "stop", true)
+		}
+	case "fire":
+		fired[arg(0)] = append(fired[arg(0)], task)
+	case "done":
+		awaited[arg(0)] = append(awaited[arg(0)], task)
+	case "repeat":
+		g.edge(task, task, "repeat", false)
+	case "invoke":
+		g.node("invoke:"+arg(0), "invoke "+arg(0), nodeExternal)
+		g.edge(task, "invoke:"+arg(0), "invoke", false)
+	case "startquest":
+		g.node("quest:"+arg(0), "quest "+arg(0), nodeExternal)
+		g.edge(task, "quest:"+arg(0), "start", false)
+	case "send":
+		g.node("quest:"+arg(0), "quest "+arg(0), nodeExternal)
+		g.edge(task, "quest:"+arg(0), "send "+arg(1), false)
+	case "finish", "fail":
+		g.node(c.name, c.name, nodeExit)
+		g.edge(task, c.name, "", false)
+	}
+
+	if c.target != "" && isTask(c.target) {
+		g.edge(task, c.target, "timeout "+c.timeout, true)
+	}
+}
+
+func (g *questGraph) node(name, label string, kind int) *questGraphNode {
+	if n, ok := g.byName[name]; ok {
+		return n
+	}
+
+	n := &questGraphNode{
+		id:    fmt.Sprintf("n%d", len(g.nodes)),
+		label: label,
+		kind:  kind,
+	}
+
+	g.nodes = append(g.nodes, n)
+	g.byName[name] = n
+	return n
+}
+
+func (g *questGraph) edge(from, to, label string, dashed bool) {
+	e := questGraphEdge{
+		from:   g.byName[from].id,
+		to:     g.byName[to].id,
+		label:  label,
+		dashed: dashed,
+	}
+
+	if g.seen[e] {
+		return
+	}
+
+	g.seen[e] = true
+	g.edges = append(g.edges, e)
+}
+
+// applyProgress marks tasks by the state they were saved in
+func (g *questGraph) applyProgress(qs *questProgressQuest) {
+	for _, t := range qs.Tasks {
+		n, ok := g.byName[t.Name]
+
+		if !ok {
+			continue
+		}
+
+		switch {
+		case t.Done:
+			n.progress = progressDone
+		case t.Dormant:
+			n.progress = progressDormant
+		default:
+			n.progress = progressPending
+		}
+	}
+
+	if exit, ok := g.byName[map[string]string{"finished": "finish", "failed": "fail"}[qs.State]]; ok {
+		exit.progress = progressDone
+	}
+}
+
+var questGraphDOTShapes = map[int]string{
+	nodeEntry:    "circle",
+	nodeTask:     "box",
+	nodeEvent:    "hexagon",
+	nodeExternal: "note",
+	nodeExit:     "doublecircle",
+}
+
+var questGraphDOTColors = map[int]string{
+	progressPending: "gold",
+	progressDone:    "palegreen",
+	progressDormant: "lightgray",
+}
+
+func (g *questGraph) writeDOT(w io.Writer) {
+	fmt.Fprintf(w, "digraph %q {\n", g.name)
+	fmt.Fprintf(w, "    rankdir=LR;\n")
+
+	for _, n := range g.nodes {
+		attrs := []string{
+			fmt.Sprintf("label=%q", n.label),
+			"shape=" + questGraphDOTShapes[n.kind],
+		}
+
+		styles := []string{}
+
+		if n.dormant {
+			styles = append(styles, "dashed")
+		}
+
+		if color, ok := questGraphDOTColors[n.progress]; ok {
+			styles = append(styles, "filled")
+			attrs = append(attrs, "fillcolor="+color)
+		}
+
+		if len(styles) > 0 {
+			attrs = append(attrs, fmt.Sprintf("style=%q", strings.Join(styles, ",")))
+		}
+
+		fmt.Fprintf(w, "    %s [%s];\n", n.id, strings.Join(attrs, ", "))
+	}
+
+	for _, e := range g.edges {
+		attrs := []string{}
+
+		if e.label != "" {
+			attrs = append(attrs, fmt.Sprintf("label=%q", e.label))
+		}
+
+		if e.dashed {
+			attrs = append(attrs, "style=dashed")
+		}
+
+		fmt.Fprintf(w, "    %s -> %s", e.from, e.to)
+
+		if len(attrs) > 0 {
+			fmt.Fprintf(w, " [%s]", strings.Join(attrs, ", "))
+		}
+
+		fmt.Fprintf(w, ";\n")
+	}
+
+	fmt.Fprintf(w, "}\n")
+}
+
+var questGraphMermaidShapes = map[int][2]string{
+	nodeEntry:    {"((", "))"},
+	nodeTask:     {"[", "]"},
+	nodeEvent:    {"{{", "}}"},
+	nodeExternal: {">", "]"},
+	nodeExit:     {"(((", ")))"},
+}
+
+var questGraphMermaidClasses = map[int]string{
+	progressPending: "pending",
+	progressDone:    "done",
+	progressDormant: "dormant",
+}
+
+func (g *questGraph) writeMermaid(w io.Writer) {
+	fmt.Fprintf(w, "flowchart LR\n")
+
+	for _, n := range g.nodes {
+		shape := questGraphMermaidShapes[n.kind]
+		fmt.Fprintf(w, "    %s%s\"%s\"%s", n.id, shape[0], strings.Replace(n.label, "\"", "#quot;", -1), shape[1])
+
+		if class, ok := questGraphMermaidClasses[n.progress]; ok {
+			fmt.Fprintf(w, ":::%s", class)
+		}
+
+		fmt.Fprintf(w, "\n")
+	}
+
+	for _, e := range g.edges {
+		arrow := "-->"
+
+		if e.dashed {
+			arrow = "-.->"
+		}
+
+		if e.label != "" {
+			arrow += "|" + e.label + "|"
+		}
+
+		fmt.Fprintf(w, "    %s %s %s\n", e.from, arrow, e.to)
+	}
+
+	fmt.Fprintf(w, "    classDef pending fill:#ffd700\n")
+	fmt.Fprintf(w, "    classDef done fill:#98fb98\n")
+	fmt.Fprintf(w, "    classDef dormant fill:#d3d3d3\n")
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+const sequentialTestQuest = `TITLE: Sequential
++SEQUENTIAL
+
+QST:
+
+setvar ready 1
+
+event talk:
+    finish
+
+task find:
+    wait 1
+
+task return:
+    wait 1
+
+task reward:
+    finish
+`
+
+func TestQuestGraphSequentialDormancy(t *testing.T) {
+	withQuestFiles(t, map[string]string{
+		"quests/sequential.qst": sequentialTestQuest,
+	})
+
+	def := parseQuest("sequential")
+
+	if def == nil {
+		t.Fatal("quest could not be parsed")
+	}
+
+	g := buildQuestGraph("sequential", def)
+	_, reason, id := currentGameMode.quests.addQuest("sequential", nil)
+	qs := currentGameMode.quests.findQuest(id)
+
+	if qs == nil {
+		t.Fatalf("quest could not be added: %s", reason)
+	}
+
+	// the graph shows the tasks as they are when the quest starts
+	for _, v := range qs.tasks {
+		if n := g.byName[v.name]; n.dormant != v.isDormant {
+			t.Errorf("got task '%s' dormant = %v in the graph, want %v", v.name, n.dormant, v.isDormant)
+		}
+	}
+
+	if g.byName["find"].dormant || !g.byName["return"].dormant {
+		t.Error("want only tasks after the first one to be dormant")
+	}
+}
+
+func TestQuestProgressFromSave(t *testing.T) {
+	withQuestFiles(t, map[string]string{
+		"quests/sequential.qst": sequentialTestQuest,
+	})
+
+	q := &currentGameMode.quests
+	_, reason, id := q.addQuest("sequential", nil)
+
+	if !q.abandonQuest(id) {
+		t.Fatalf("quest could not be abandoned: %s", reason)
+	}
+
+	path := filepath.Join(t.TempDir(), "quests.json")
+	saveQuestProgress(path, q.save())
+	res, err := loadQuestProgress(path)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	qs := res.find("sequential")
+
+	if qs == nil || qs.State != "abandoned" {
+		t.Fatalf("got %+v, want an abandoned quest", qs)
+	}
+
+	for _, v := range qs.Tasks {
+		if v.Event != (v.Name == "talk") {
+			t.Errorf("got task '%s' event = %v", v.Name, v.Event)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+/*
+	Invoke allow-list
+
+	Quests can only 'invoke' core events registered here, each comes with a signature
+	of its arguments and the permission a quest needs to fire it. Calls are checked
+	when the quest is parsed and again when they run.
+
+	Quests shipped with the game are loaded from 'quests', modded quests from 'mods/quests',
+	they can only fire events that are safe for third-party content.
+*/
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	permModded = iota
+	permBase
+)
+
+const (
+	questModsDir = "mods"
+)
+
+var questPermissionNames = map[int]string{
+	permModded: "modded",
+	permBase:   "base",
+}
+
+// questInvokeEvent is a core event quests are allowed to fire
+type questInvokeEvent struct {
+	questCommand
+	permission int
+}
+
+var questInvokeEvents map[string]*questInvokeEvent
+
+// questInitInvokeEvents registers core events quests can fire
+func questInitInvokeEvents() {
+	questInvokeEvents = map[string]*questInvokeEvent{}
+
+	registerInvokeEvent("follow", "<object>", permModded, "Makes the camera follow the object")
+	registerInvokeEvent("trigger", "<object>", permModded, "Triggers the object as if the player has touched it")
+	registerInvokeEvent("loadMap", "<map>", permBase, "Loads the map, ending the current level")
+	registerInvokeEvent("setCanSave", "<state:number>", permBase, "Allows or forbids saving the game, 1 or 0")
+}
+
+func registerInvokeEvent(name, sig string, permission int, desc string) {
+	questInvokeEvents[name] = &questInvokeEvent{
+		questCommand: questCommand{
+			name: name,
+			args: parseQuestSignature(name, sig),
+			desc: desc,
+		},
+		permission: permission,
+	}
+}
+
+// findInvokeEvent looks up an event quests are allowed to fire
+func findInvokeEvent(name string) *questInvokeEvent {
+	if questInvokeEvents == nil {
+		questInitInvokeEvents()
+	}
+
+	return questInvokeEvents[name]
+}
+
+// checkInvoke validates arguments of 'invoke' against the allow-list,
+// it returns an empty string when the quest can fire the event
+func checkInvoke(args []string, permission int) string {
+	if len(args) == 0 {
+		return ""
+	}
+
+	ev := findInvokeEvent(args[0])
+
+	if ev == nil {
+		return fmt.Sprintf("event '%s' is not allowed to be invoked", args[0])
+	}
+
+	if permission < ev.permission {
+		return fmt.Sprintf("event '%s' can't be invoked by %s quests", args[0], questPermissionNames[permission])
+	}
+
+	if !ev.acceptsArgs(len(args) - 1) {
+		return fmt.Sprintf("event '%s' needs %s arguments, got: '%d'", args[0], ev.expectedArgs(), len(args)-1)
+	}
+
+	// core events get the arguments as they are, so numbers have to be literals
+	for i, v := range args[1:] {
+		if arg := ev.argAt(i); arg != nil && arg.kind == argNumber {
+			if _, err := strconv.ParseFloat(v, 64); err != nil {
+				return fmt.Sprintf("event '%s' needs a number for '%s', got: '%s'", args[0], arg.name, v)
+			}
+		}
+	}
+
+	return ""
+}
+
+// checkInvoke reports calls of 'invoke' the quest isn't allowed to make
+func (p *questParser) checkInvoke(qc questCmd, pos int) {
+	if qc.name != "invoke" {
+		return
+	}
+
+	if msg := checkInvoke(qc.args, p.permission); msg != "" {
+		p.reportf(pos, "%s", msg)
+	}
+}
+
+// questFilePermission returns the permission of a quest by the directory it's stored in
+func questFilePermission(file string) int {
+	dirs := strings.Split(filepath.ToSlash(file), "/")
+
+	for i := 0; i+1 < len(dirs); i++ {
+		if dirs[i] == questModsDir && dirs[i+1] == "quests" {
+			return permModded
+		}
+	}
+
+	return permBase
+}
+
+// invokeEventReference lists events quests can fire
+func invokeEventReference() string {
+	if questInvokeEvents == nil {
+		questInitInvokeEvents()
+	}
+
+	names := []string{}
+
+	for k := range questInvokeEvents {
+		names = append(names, k)
+	}
+
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString("\n")
+
+	for _, v := range names {
+		ev := questInvokeEvents[v]
+		fmt.Fprintf(&b, "- `%s` (%s)\n    %s\n", strings.TrimSpace(ev.name+" "+ev.usage(true)), questPermissionNames[ev.permission], ev.desc)
+	}
+
+	b.WriteString("\n")
+	return b.String()
+}
@@ -0,0 +1,427 @@
+package main
+
+/*
+	Quest analysis used by the language server
+
+	The quest is parsed the same way as in game, the parser additionally
+	records declarations of tasks, events, resources, macros and constants.
+	Variables, timers and labels are declared by commands.
+*/
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	declTask = iota
+	declEvent
+	declResource
+	declMacro
+	declConstant
+	declVariable
+	declTimer
+	declLabel
+)
+
+var questDeclKinds = map[string]int{
+	kwTask:  declTask,
+	kwEvent: declEvent,
+}
+
+var questDeclKindNames = map[int]string{
+	declTask:     "task",
+	declEvent:    "event",
+	declResource: "resource",
+	declMacro:    "macro",
+	declConstant: "constant",
+	declVariable: "variable",
+	declTimer:    "timer",
+	declLabel:    "label",
+}
+
+// questDeclCommands lists commands declaring a name in their first argument
+var questDeclCommands = map[string]int{
+	"variable": declVariable,
+	"setvar":   declVariable,
+	"setstr":   declVariable,
+	"vec":      declVariable,
+	"setvec":   declVariable,
+	"copyvec":  declVariable,
+	"addvec":   declVariable,
+	"addivec":  declVariable,
+	"subvec":   declVariable,
+	"subivec":  declVariable,
+	"divivec":  declVariable,
+	"mulvec":   declVariable,
+	"normvec":  declVariable,
+	"flipvec":  declVariable,
+	"pop":      declVariable,
+	"timer":    declTimer,
+	"label":    declLabel,
+}
+
+var questLanguageCommands map[string]*questCommand
+
+// questArgRefKinds returns declarations the command's n-th argument may refer to
+func questArgRefKinds(cmd string, n int) []int {
+	c, ok := questLanguageCommands[cmd]
+
+	if !ok || n < 0 {
+		return nil
+	}
+
+	if arg := c.argAt(n); arg != nil {
+		return questArgRefs[arg.kind]
+	}
+
+	return nil
+}
+
+type questDecl struct {
+	kind   int
+	name   string
+	file   string
+	pos    int
+	detail string
+}
+
+// questIndex holds a parsed quest along with its declarations
+type questIndex struct {
+	file        string
+	data        []byte
+	def         *questDef
+	decls       []questDecl
+	diagnostics []questParseDiagnostic
+}
+
+// declare records a declaration when the quest is being edited
+func (p *questParser) declare(kind int, name string, pos int, detail string) {
+	if p.decls == nil {
+		return
+	}
+
+	*p.decls = append(*p.decls, questDecl{
+		kind:   kind,
+		name:   name,
+		file:   p.file,
+		pos:    pos,
+		detail: detail,
+	})
+}
+
+// analyzeQuest parses a quest being edited, syntax errors are reported instead of stopping the game
+func analyzeQuest(file string, data []byte, read func(name string) []byte) *questIndex {
+	if questLanguageCommands == nil {
+		questLanguageCommands = makeQuestManager().commands
+	}
+
+	idx := &questIndex{
+		file: file,
+		data: data,
+		def: &questDef{
+			category:   catSide,
+			resources:  map[int]questResource{},
+			permission: questFilePermission(file),
+		},
+	}
+
+	p := questParser{
+		data:          data,
+		file:          file,
+		diagnostics:   &idx.diagnostics,
+		macros:        map[string]questMacro{},
+		symbols:       map[string]string{},
+		resourceNames: map[string]int{},
+		included:      map[string]bool{},
+		recoverable:   true,
+		readAsset:     read,
+		decls:         &idx.decls,
+		permission:    idx.def.permission,
+	}
+
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				if _, ok := r.(questParseAbort); !ok {
+					p.reportf(p.textPos, "parser has crashed: %v", r)
+				}
+			}
+		}()
+
+		p.checkEncoding()
+		p.parseDefinition(idx.def, false)
+	}()
+
+	idx.def.resourceNames = p.resourceNames
+	idx.declareCommands()
+	idx.lint()
+
+	return idx
+}
+
+// declareCommands records variables, timers and labels at their first use,
+// names declared by libraries have no position
+func (idx *questIndex) declareCommands() {
+	seen := map[string]bool{}
+
+	declare := func(tasks []questTaskDef, file string) {
+		for _, t := range tasks {
+			for _, c := range t.commands {
+				kind, ok := questDeclCommands[c.name]
+
+				if !ok || len(c.args) == 0 || seen[c.args[0]] {
+					continue
+				}
+
+				pos := c.pos
+
+				if file == "" {
+					pos = -1
+				}
+
+				seen[c.args[0]] = true
+				idx.decls = append(idx.decls, questDecl{
+					kind: kind,
+					name: c.args[0],
+					file: file,
+					pos:  pos,
+				})
+			}
+		}
+	}
+
+	declare(idx.def.taskDef, idx.file)
+	declare(idx.def.libraryTasks, "")
+
+	for _, v := range idx.def.params {
+		if !seen[v.name] {
+			seen[v.name] = true
+			idx.decls = append(idx.decls, questDecl{
+				kind:   declVariable,
+				name:   v.name,
+				pos:    -1,
+				detail: fmt.Sprintf("parameter (%s)", questVarKindName(v.kind)),
+			})
+		}
+	}
+}
+
+// lint reports unknown commands and references to missing declarations
+func (idx *questIndex) lint() {
+	report := func(pos int, format string, args ...interface{}) {
+		idx.diagnostics = append(idx.diagnostics, questParseDiagnostic{
+			file:    idx.file,
+			line:    strings.Count(string(idx.data[:pos]), "\n") + 1,
+			pos:     pos,
+			message: fmt.Sprintf(format, args...),
+		})
+	}
+
+	for _, t := range idx.def.taskDef {
+		for _, c := range t.commands {
+			cmd, ok := questLanguageCommands[c.name]
+
+			if !ok {
+				report(c.pos, "unknown command '%s'", c.name)
+				continue
+			}
+
+			if !cmd.acceptsArgs(len(c.args)) {
+				report(c.pos, "command '%s' needs %s arguments, got: '%d'", c.name, cmd.expectedArgs(), len(c.args))
+				continue
+			}
+
+			for i, v := range c.args {
+				kinds := questArgRefKinds(c.name, i)
+
+				if kinds == nil || strings.HasPrefix(v, kwLeftBrace) {
+					continue
+				}
+
+				if kinds[0] == declResource {
+					id, err := strconv.Atoi(v)
+
+					if _, found := idx.def.resources[id]; err != nil || !found {
+						report(c.pos, "resource '%s' is not defined", v)
+					}
+
+					continue
+				}
+
+				if idx.find(v, kinds...) == nil {
+					report(c.pos, "%s '%s' is not defined", questDeclKindNames[kinds[0]], v)
+				}
+			}
+		}
+	}
+}
+
+// find looks up a declaration by its name, any kind matches when none is given
+func (idx *questIndex) find(name string, kinds ...int) *questDecl {
+	for i, d := range idx.decls {
+		if d.name != name {
+			continue
+		}
+
+		if len(kinds) == 0 {
+			return &idx.decls[i]
+		}
+
+		for _, k := range kinds {
+			if d.kind == k {
+				return &idx.decls[i]
+			}
+		}
+	}
+
+	return nil
+}
+
+// resource finds the declaration of a resource by its name or numeric ID
+func (idx *questIndex) resource(word string) *questDecl {
+	if d := idx.find(word, declResource); d != nil {
+		return d
+	}
+
+	id, err := strconv.Atoi(word)
+
+	if err != nil {
+		return nil
+	}
+
+	for name, v := range idx.def.resourceNames {
+		if v == id {
+			return idx.find(name, declResource)
+		}
+	}
+
+	return nil
+}
+
+// commandNames lists registered commands and macros in sorted order
+func (idx *questIndex) commandNames() []string {
+	res := []string{}
+
+	for k := range questLanguageCommands {
+		res = append(res, k)
+	}
+
+	for _, d := range idx.decls {
+		if d.kind == declMacro {
+			res = append(res, d.name)
+		}
+	}
+
+	sort.Strings(res)
+	return res
+}
+
+// commandAt returns the command on the line of the given byte offset
+// and the index of the argument being written, -1 when it's the command itself
+func (idx *questIndex) commandAt(off int) (string, int) {
+	start := strings.LastIndexByte(string(idx.data[:off]), '\n') + 1
+	fields := strings.Fields(string(idx.data[start:off]))
+
+	if len(fields) == 0 {
+		return "", -1
+	}
+
+	arg := len(fields) - 1
+
+	if r, _ := utf8.DecodeLastRune(idx.data[:off]); unicode.IsSpace(r) {
+		arg++
+	}
+
+	return strings.ToLower(fields[0]), arg - 1
+}
+
+// wordAt returns the word at the byte offset and its start
+func (idx *questIndex) wordAt(off int) (string, int) {
+	isWord := func(r rune) bool {
+		return !unicode.IsSpace(r) && !strings.ContainsRune(":(),%", r)
+	}
+
+	start, end := off, off
+
+	for start > 0 {
+		r, size := utf8.DecodeLastRune(idx.data[:start])
+
+		if !isWord(r) {
+			break
+		}
+
+		start -= size
+	}
+
+	for end < len(idx.data) {
+		r, size := utf8.DecodeRune(idx.data[end:])
+
+		if !isWord(r) {
+			break
+		}
+
+		end += size
+	}
+
+	return string(idx.data[start:end]), start
+}
+
+// position converts a byte offset into a line and a column counted in UTF-16 units, as used by editors
+func position(data []byte, off int) (int, int) {
+	if off > len(data) {
+		off = len(data)
+	}
+
+	line, col := 0, 0
+
+	for i := 0; i < off; {
+		r, size := utf8.DecodeRune(data[i:])
+
+		if r == '\n' {
+			line++
+			col = 0
+		} else if r >= 0x10000 {
+			col += 2
+		} else {
+			col++
+		}
+
+		i += size
+	}
+
+	return line, col
+}
+
+// offset converts a line and a UTF-16 column into a byte offset
+func offset(data []byte, line, col int) int {
+	i := 0
+
+	for ; line > 0 && i < len(data); i++ {
+		if data[i] == '\n' {
+			line--
+		}
+	}
+
+	for col > 0 && i < len(data) {
+		r, size := utf8.DecodeRune(data[i:])
+
+		if r == '\n' {
+			break
+		}
+
+		if r >= 0x10000 {
+			col -= 2
+		} else {
+			col--
+		}
+
+		i += size
+	}
+
+	return i
+}
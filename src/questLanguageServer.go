@@ -0,0 +1,609 @@
+package main
+
+/*
+	Language server for the quest language
+
+	Started by the '-lsp' option, it talks the Language Server Protocol over
+	the standard input and output, so any editor supporting it can be used.
+	Documents are synchronized in full, libraries are read from the 'quests'
+	directory the document lives in.
+*/
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// LSP constants used by the server
+const (
+	lspSyncFull = 1
+
+	lspSeverityError = 1
+
+	lspErrMethodNotFound = -32601
+	lspErrInvalidParams  = -32602
+
+	lspCompletionFunction  = 3
+	lspCompletionVariable  = 6
+	lspCompletionModule    = 9
+	lspCompletionKeyword   = 14
+	lspCompletionReference = 18
+	lspCompletionConstant  = 21
+	lspCompletionEvent     = 23
+
+	lspSymbolFunction = 12
+	lspSymbolEvent    = 24
+)
+
+var questDeclCompletionKinds = map[int]int{
+	declTask:     lspCompletionFunction,
+	declEvent:    lspCompletionEvent,
+	declResource: lspCompletionReference,
+	declMacro:    lspCompletionModule,
+	declConstant: lspCompletionConstant,
+	declVariable: lspCompletionVariable,
+	declTimer:    lspCompletionVariable,
+	declLabel:    lspCompletionReference,
+}
+
+type lspMessage struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method,omitempty"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+	Result  interface{}      `json:"result,omitempty"`
+	Error   *lspError        `json:"error,omitempty"`
+}
+
+type lspError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type lspPosition struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type lspRange struct {
+	Start lspPosition `json:"start"`
+	End   lspPosition `json:"end"`
+}
+
+type lspLocation struct {
+	URI   string   `json:"uri"`
+	Range lspRange `json:"range"`
+}
+
+type lspDocumentPosition struct {
+	TextDocument struct {
+		URI string `json:"uri"`
+	} `json:"textDocument"`
+	Position lspPosition `json:"position"`
+}
+
+type lspDiagnostic struct {
+	Range    lspRange `json:"range"`
+	Severity int      `json:"severity"`
+	Source   string   `json:"source"`
+	Message  string   `json:"message"`
+}
+
+type lspCompletionItem struct {
+	Label         string `json:"label"`
+	Kind          int    `json:"kind"`
+	Detail        string `json:"detail,omitempty"`
+	Documentation string `json:"documentation,omitempty"`
+}
+
+type lspDocumentSymbol struct {
+	Name           string   `json:"name"`
+	Detail         string   `json:"detail,omitempty"`
+	Kind           int      `json:"kind"`
+	Range          lspRange `json:"range"`
+	SelectionRange lspRange `json:"selectionRange"`
+}
+
+type languageServer struct {
+	in   *bufio.Reader
+	out  io.Writer
+	docs map[string]*questIndex
+}
+
+// runLanguageServer serves editor requests until the editor asks us to exit
+func runLanguageServer(in io.Reader, out io.Writer) {
+	// the parser logs every task it adds, editors don't need that
+	log.SetOutput(ioutil.Discard)
+
+	s := &languageServer{
+		in:   bufio.NewReader(in),
+		out:  out,
+		docs: map[string]*questIndex{},
+	}
+
+	for {
+		msg, err := s.read()
+
+		if err == io.EOF {
+			return
+		}
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Language server: %s\n", err)
+			return
+		}
+
+		if msg.Method == "exit" {
+			return
+		}
+
+		s.handle(msg)
+	}
+}
+
+func (s *languageServer) read() (*lspMessage, error) {
+	length := -1
+
+	for {
+		line, err := s.in.ReadString('\n')
+
+		if err != nil {
+			return nil, err
+		}
+
+		line = strings.TrimSpace(line)
+
+		if line == "" {
+			break
+		}
+
+		if strings.HasPrefix(strings.ToLower(line), "content-length:") {
+			length, err = strconv.Atoi(strings.TrimSpace(line[len("content-length:"):]))
+
+			if err != nil {
+				return nil, fmt.Errorf("invalid header '%s'", line)
+			}
+		}
+	}
+
+	if length < 0 {
+		return nil, fmt.Errorf("message has no Content-Length")
+	}
+
+	body := make([]byte, length)
+
+	if _, err := io.ReadFull(s.in, body); err != nil {
+		return nil, err
+	}
+
+	msg := &lspMessage{}
+
+	if err := json.Unmarshal(body, msg); err != nil {
+		return nil, err
+	}
+
+	return msg, nil
+}
+
+func (s *languageServer) write(msg *lspMessage) {
+	msg.JSONRPC = "2.0"
+	body, err := json.Marshal(msg)
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Language server: %s\n", err)
+		return
+	}
+
+	fmt.Fprintf(s.out, "Content-Length: %d\r\n\r\n%s", len(body), body)
+}
+
+func (s *languageServer) reply(id *json.RawMessage, result interface{}) {
+	if result == nil {
+		// the result has to be present, even when it's null
+		raw := json.RawMessage("null")
+		result = &raw
+	}
+
+	s.write(&lspMessage{ID: id, Result: result})
+}
+
+func (s *languageServer) fail(id *json.RawMessage, code int, format string, args ...interface{}) {
+	s.write(&lspMessage{ID: id, Error: &lspError{Code: code, Message: fmt.Sprintf(format, args...)}})
+}
+
+func (s *languageServer) notify(method string, params interface{}) {
+	data, _ := json.Marshal(params)
+	s.write(&lspMessage{Method: method, Params: data})
+}
+
+func (s *languageServer) handle(msg *lspMessage) {
+	var pos lspDocumentPosition
+
+	switch msg.Method {
+	case "textDocument/completion", "textDocument/definition", "textDocument/hover":
+		if err := json.Unmarshal(msg.Params, &pos); err != nil {
+			s.fail(msg.ID, lspErrInvalidParams, "%s", err)
+			return
+		}
+	}
+
+	switch msg.Method {
+	case "initialize":
+		s.reply(msg.ID, map[string]interface{}{
+			"capabilities": map[string]interface{}{
+				"textDocumentSync":       lspSyncFull,
+				"completionProvider":     map[string]interface{}{},
+				"definitionProvider":     true,
+				"hoverProvider":          true,
+				"documentSymbolProvider": true,
+			},
+			"serverInfo": map[string]string{
+				"name": "questls",
+			},
+		})
+
+	case "shutdown":
+		s.reply(msg.ID, nil)
+
+	case "textDocument/didOpen":
+		var params struct {
+			TextDocument struct {
+				URI  string `json:"uri"`
+				Text string `json:"text"`
+			} `json:"textDocument"`
+		}
+
+		if json.Unmarshal(msg.Params, &params) == nil {
+			s.update(params.TextDocument.URI, params.TextDocument.Text)
+		}
+
+	case "textDocument/didChange":
+		var params struct {
+			TextDocument struct {
+				URI string `json:"uri"`
+			} `json:"textDocument"`
+			ContentChanges []struct {
+				Text string `json:"text"`
+			} `json:"contentChanges"`
+		}
+
+		if json.Unmarshal(msg.Params, &params) == nil && len(params.ContentChanges) > 0 {
+			s.update(params.TextDocument.URI, params.ContentChanges[len(params.ContentChanges)-1].Text)
+		}
+
+	case "textDocument/didClose":
+		var params struct {
+			TextDocument struct {
+				URI string `json:"uri"`
+			} `json:"textDocument"`
+		}
+
+		if json.Unmarshal(msg.Params, &params) == nil {
+			delete(s.docs, params.TextDocument.URI)
+		}
+
+	case "textDocument/completion":
+		s.reply(msg.ID, s.completion(pos))
+
+	case "textDocument/definition":
+		s.reply(msg.ID, s.definition(pos))
+
+	case "textDocument/hover":
+		s.reply(msg.ID, s.hover(pos))
+
+	case "textDocument/documentSymbol":
+		var params struct {
+			TextDocument struct {
+				URI string `json:"uri"`
+			} `json:"textDocument"`
+		}
+
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			s.fail(msg.ID, lspErrInvalidParams, "%s", err)
+			return
+		}
+
+		s.reply(msg.ID, s.symbols(params.TextDocument.URI))
+
+	default:
+		// notifications we don't care about are ignored
+		if msg.ID != nil {
+			s.fail(msg.ID, lspErrMethodNotFound, "method '%s' is not supported", msg.Method)
+		}
+	}
+}
+
+// update analyzes a document and publishes its diagnostics
+func (s *languageServer) update(uri, text string) {
+	path := uriToPath(uri)
+	root := questAssetsRoot(path)
+
+	idx := analyzeQuest(path, []byte(text), func(name string) []byte {
+		data, err := ioutil.ReadFile(filepath.Join(root, filepath.FromSlash(name)))
+
+		if err != nil {
+			return nil
+		}
+
+		return data
+	})
+
+	s.docs[uri] = idx
+	diags := []lspDiagnostic{}
+
+	for _, v := range idx.diagnostics {
+		d := lspDiagnostic{
+			Severity: lspSeverityError,
+			Source:   "quest",
+			Message:  v.message,
+		}
+
+		if v.file == idx.file {
+			d.Range = lspWordRange(idx.data, v.pos)
+		} else {
+			// problems of included libraries are shown at the top of the quest
+			d.Message = v.String()
+		}
+
+		diags = append(diags, d)
+	}
+
+	s.notify("textDocument/publishDiagnostics", map[string]interface{}{
+		"uri":         uri,
+		"diagnostics": diags,
+	})
+}
+
+func (s *languageServer) completion(pos lspDocumentPosition) []lspCompletionItem {
+	res := []lspCompletionItem{}
+	idx, ok := s.docs[pos.TextDocument.URI]
+
+	if !ok {
+		return res
+	}
+
+	cmd, arg := idx.commandAt(offset(idx.data, pos.Position.Line, pos.Position.Character))
+
+	if arg < 0 {
+		for _, v := range idx.commandNames() {
+			item := lspCompletionItem{
+				Label: v,
+				Kind:  lspCompletionKeyword,
+			}
+
+			if c, ok := questLanguageCommands[v]; ok {
+				item.Detail = strings.TrimSpace(v + " " + c.usage(true))
+				item.Documentation = c.desc
+			} else if d := idx.find(v, declMacro); d != nil {
+				item.Kind = lspCompletionModule
+				item.Detail = fmt.Sprintf("macro %s(%s)", v, d.detail)
+			}
+
+			res = append(res, item)
+		}
+
+		for _, v := range []string{kwTask, kwEvent} {
+			res = append(res, lspCompletionItem{Label: v, Kind: lspCompletionKeyword})
+		}
+
+		return res
+	}
+
+	// arguments of some commands refer to a specific kind of declaration
+	kinds := questArgRefKinds(cmd, arg)
+
+	if kinds == nil {
+		kinds = []int{declVariable, declConstant, declTimer}
+	}
+
+	seen := map[string]bool{}
+
+	for _, d := range idx.decls {
+		match := false
+
+		for _, k := range kinds {
+			match = match || d.kind == k
+		}
+
+		if !match || seen[d.name] {
+			continue
+		}
+
+		seen[d.name] = true
+		res = append(res, lspCompletionItem{
+			Label:         d.name,
+			Kind:          questDeclCompletionKinds[d.kind],
+			Detail:        questDeclKindNames[d.kind],
+			Documentation: d.detail,
+		})
+	}
+
+	return res
+}
+
+func (s *languageServer) definition(pos lspDocumentPosition) interface{} {
+	idx, ok := s.docs[pos.TextDocument.URI]
+
+	if !ok {
+		return nil
+	}
+
+	d := s.lookup(idx, pos)
+
+	if d == nil || d.pos < 0 {
+		return nil
+	}
+
+	data := idx.data
+	uri := pos.TextDocument.URI
+
+	if d.file != idx.file {
+		path := filepath.Join(questAssetsRoot(idx.file), filepath.FromSlash(d.file))
+		lib, err := ioutil.ReadFile(path)
+
+		if err != nil {
+			return nil
+		}
+
+		data = lib
+		uri = pathToURI(path)
+	}
+
+	return lspLocation{
+		URI:   uri,
+		Range: lspWordRange(data, d.pos),
+	}
+}
+
+func (s *languageServer) hover(pos lspDocumentPosition) interface{} {
+	idx, ok := s.docs[pos.TextDocument.URI]
+
+	if !ok {
+		return nil
+	}
+
+	var text string
+	off := offset(idx.data, pos.Position.Line, pos.Position.Character)
+	word, _ := idx.wordAt(off)
+	_, arg := idx.commandAt(off)
+
+	if c, ok := questLanguageCommands[strings.ToLower(word)]; ok && arg < 0 {
+		text = fmt.Sprintf("```\n%s %s\n```\n%s", c.name, c.usage(true), c.desc)
+	} else if d := s.lookup(idx, pos); d != nil {
+		switch d.kind {
+		case declResource:
+			text = d.detail
+		case declMacro:
+			text = fmt.Sprintf("```\nmacro %s(%s)\n```", d.name, d.detail)
+		case declConstant:
+			text = fmt.Sprintf("```\n%s = %s\n```", d.name, d.detail)
+		default:
+			text = fmt.Sprintf("%s `%s` %s", questDeclKindNames[d.kind], d.name, d.detail)
+		}
+	}
+
+	if text == "" {
+		return nil
+	}
+
+	return map[string]interface{}{
+		"contents": map[string]string{
+			"kind":  "markdown",
+			"value": text,
+		},
+	}
+}
+
+// lookup finds the declaration of a word under the cursor, resources are looked up by IDs as well
+func (s *languageServer) lookup(idx *questIndex, pos lspDocumentPosition) *questDecl {
+	off := offset(idx.data, pos.Position.Line, pos.Position.Character)
+	word, _ := idx.wordAt(off)
+
+	if word == "" {
+		return nil
+	}
+
+	cmd, arg := idx.commandAt(off)
+
+	if kinds := questArgRefKinds(cmd, arg); kinds != nil {
+		if kinds[0] == declResource {
+			return idx.resource(word)
+		}
+
+		return idx.find(word, kinds...)
+	}
+
+	if d := idx.find(word); d != nil {
+		return d
+	}
+
+	return idx.resource(word)
+}
+
+func (s *languageServer) symbols(uri string) []lspDocumentSymbol {
+	res := []lspDocumentSymbol{}
+	idx, ok := s.docs[uri]
+
+	if !ok {
+		return res
+	}
+
+	for _, d := range idx.decls {
+		if d.file != idx.file || (d.kind != declTask && d.kind != declEvent) {
+			continue
+		}
+
+		kind := lspSymbolFunction
+
+		if d.kind == declEvent {
+			kind = lspSymbolEvent
+		}
+
+		r := lspWordRange(idx.data, d.pos)
+		res = append(res, lspDocumentSymbol{
+			Name:           d.name,
+			Detail:         questDeclKindNames[d.kind],
+			Kind:           kind,
+			Range:          r,
+			SelectionRange: r,
+		})
+	}
+
+	return res
+}
+
+// lspWordRange returns the range of a word starting at the byte offset
+func lspWordRange(data []byte, off int) lspRange {
+	end := off
+
+	for end < len(data) && !strings.ContainsRune(" \t\r\n:(", rune(data[end])) {
+		end++
+	}
+
+	sl, sc := position(data, off)
+	el, ec := position(data, end)
+
+	return lspRange{
+		Start: lspPosition{Line: sl, Character: sc},
+		End:   lspPosition{Line: el, Character: ec},
+	}
+}
+
+// questAssetsRoot finds the assets directory of a quest, it's the parent of the 'quests' directory
+func questAssetsRoot(path string) string {
+	for dir := filepath.Dir(path); dir != filepath.Dir(dir); dir = filepath.Dir(dir) {
+		if filepath.Base(dir) == "quests" {
+			return filepath.Dir(dir)
+		}
+	}
+
+	return filepath.Dir(path)
+}
+
+func uriToPath(uri string) string {
+	u, err := url.Parse(uri)
+
+	if err != nil || u.Scheme != "file" {
+		return uri
+	}
+
+	return filepath.FromSlash(u.Path)
+}
+
+func pathToURI(path string) string {
+	u := url.URL{
+		Scheme: "file",
+		Path:   filepath.ToSlash(path),
+	}
+
+	return u.String()
+}
@@ -0,0 +1,290 @@
+package main
+
+/*
+	Quest libraries and macros
+
+	Libraries live in 'assets/quests/lib/*.qsl' and share the quest file's syntax,
+	they can only contain INCLUDE, DEF, QRC, QST sections and macros.
+*/
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+)
+
+const (
+	maxMacroDepth = 16
+)
+
+var (
+	macroHeaderRegex = regexp.MustCompile(`^\s*([A-Za-z_][\w]*)\s*\(([^)]*)\)\s*:\s*$`)
+)
+
+type questMacro struct {
+	name     string
+	params   []string
+	commands []questCmd
+
+	// matches each of the params as a whole word, built once per macro
+	paramRegexes []*regexp.Regexp
+}
+
+type questParseDiagnostic struct {
+	file    string
+	line    int
+	pos     int
+	message string
+}
+
+func (d questParseDiagnostic) String() string {
+	return fmt.Sprintf("%s:%d: %s", d.file, d.line, d.message)
+}
+
+// reportf records a parse diagnostic at the given byte offset
+func (p *questParser) reportf(pos int, format string, args ...interface{}) {
+	p.reportIn(p.file, p.lineAt(pos), pos, format, args...)
+}
+
+// reportIn records a parse diagnostic in another file, such as an included library
+func (p *questParser) reportIn(file string, line, pos int, format string, args ...interface{}) {
+	if p.diagnostics == nil {
+		log.Printf(format, args...)
+		return
+	}
+
+	*p.diagnostics = append(*p.diagnostics, questParseDiagnostic{
+		file:    file,
+		line:    line,
+		pos:     pos,
+		message: fmt.Sprintf(format, args...),
+	})
+}
+
+// questParseAbort stops parsing of a quest opened in an editor
+type questParseAbort struct{}
+
+// fatalf reports a syntax error the parser can't recover from, it stops the game
+// unless the quest is being edited
+func (p *questParser) fatalf(pos int, format string, args ...interface{}) {
+	if !p.recoverable {
+		log.Fatalf("%s:%d: %s\n", p.file, p.lineAt(pos), fmt.Sprintf(format, args...))
+	}
+
+	p.reportf(pos, format, args...)
+	panic(questParseAbort{})
+}
+
+func (p *questParser) lineAt(pos int) int {
+	if pos > len(p.data) {
+		pos = len(p.data)
+	}
+
+	return strings.Count(string(p.data[:pos]), "\n") + 1
+}
+
+// nextRawLine returns the rest of the current line as is
+func (p *questParser) nextRawLine() string {
+	start := p.textPos
+
+	for p.textPos < len(p.data) && p.data[p.textPos] != '\n' {
+		p.textPos++
+	}
+
+	return string(p.data[start:p.textPos])
+}
+
+// parseMacro reads a macro in form of: macro name(param, ...): <commands> end
+func (p *questParser) parseMacro(pos int) {
+	header := macroHeaderRegex.FindStringSubmatch(p.nextRawLine())
+
+	if header == nil {
+		p.fatalf(pos, "Invalid macro declaration at '%d'! Expected: 'macro name(params):'.", pos)
+		return
+	}
+
+	m := questMacro{
+		name:   strings.ToLower(header[1]),
+		params: strings.FieldsFunc(header[2], func(r rune) bool { return r == ',' || r == ' ' || r == '\t' }),
+	}
+
+	for _, v := range m.params {
+		m.paramRegexes = append(m.paramRegexes, wordRegex(v))
+	}
+
+	p.skipSeparators()
+
+	for t := p.peekToken(); ; t = p.peekToken() {
+		if t.kind == tkEndOfFile {
+			p.reportf(pos, "macro '%s' is missing its 'end'", m.name)
+			break
+		}
+
+		cmd := strings.ToLower(p.nextIdentifier())
+
+		if cmd == kwEnd {
+			break
+		}
+
+		args := []string{}
+
+		for pt := p.peekToken(); pt.kind != tkEndOfFile && pt.kind != tkSeparator; pt = p.peekToken() {
+			args = append(args, p.nextWord())
+		}
+
+		m.commands = append(m.commands, questCmd{name: cmd, args: args})
+		p.skipSeparators()
+	}
+
+	if _, ok := p.macros[m.name]; ok {
+		p.reportf(pos, "macro '%s' is already defined", m.name)
+	}
+
+	p.declare(declMacro, m.name, pos, strings.Join(m.params, ", "))
+
+	p.macros[m.name] = m
+}
+
+// expandMacro substitutes the macro's params and expands nested macros
+func (p *questParser) expandMacro(m questMacro, args []string, pos, depth int) []questCmd {
+	res := []questCmd{}
+
+	if depth >= maxMacroDepth {
+		p.reportf(pos, "macro '%s' is expanded recursively", m.name)
+		return res
+	}
+
+	if len(args) != len(m.params) {
+		p.reportf(pos, "macro '%s' needs '%d' arguments, got: '%d'", m.name, len(m.params), len(args))
+		return res
+	}
+
+	for _, v := range m.commands {
+		cmdArgs := make([]string, len(v.args))
+
+		for i, a := range v.args {
+			for j, re := range m.paramRegexes {
+				a = re.ReplaceAllLiteralString(a, args[j])
+			}
+
+			cmdArgs[i] = a
+		}
+
+		if nested, ok := p.macros[v.name]; ok {
+			res = append(res, p.expandMacro(nested, cmdArgs, pos, depth+1)...)
+		} else {
+			res = append(res, makeQuestCmd(v.name, cmdArgs))
+		}
+	}
+
+	return res
+}
+
+// wordRegex matches the word as a whole
+func wordRegex(word string) *regexp.Regexp {
+	return regexp.MustCompile(`\b` + regexp.QuoteMeta(word) + `\b`)
+}
+
+func replaceWord(s, word, with string) string {
+	return wordRegex(word).ReplaceAllLiteralString(s, with)
+}
+
+// include parses a library and merges its resources, its tasks are merged once the quest is parsed
+func (p *questParser) include(def *questDef, name string, pos int) {
+	name = strings.ToLower(name)
+
+	for _, v := range p.includes {
+		if v == name {
+			p.reportf(pos, "library '%s' is included recursively: %s -> %s", name, strings.Join(p.includes, " -> "), name)
+			return
+		}
+	}
+
+	if p.included[name] {
+		return
+	}
+
+	fileName := fmt.Sprintf("quests/lib/%s.qsl", name)
+	data := p.readAsset(fileName)
+
+	if data == nil {
+		p.reportf(pos, "library '%s' could not be found", name)
+		return
+	}
+
+	p.included[name] = true
+
+	lib := &questDef{
+		resources: map[int]questResource{},
+	}
+
+	libParser := questParser{
+		data:          data,
+		file:          fileName,
+		diagnostics:   p.diagnostics,
+		macros:        p.macros,
+		symbols:       p.symbols,
+		resourceNames: p.resourceNames,
+		includes:      append(append([]string{}, p.includes...), name),
+		included:      p.included,
+		recoverable:   p.recoverable,
+		readAsset:     p.readAsset,
+		decls:         p.decls,
+		permission:    p.permission,
+	}
+
+	libParser.checkEncoding()
+	libParser.parseDefinition(lib, true)
+	p.mergeResources(def, lib.resources, pos)
+
+	def.libraryTasks = append(def.libraryTasks, lib.libraryTasks...)
+	def.libraryTasks = append(def.libraryTasks, lib.taskDef...)
+}
+
+func (p *questParser) mergeResources(def *questDef, res map[int]questResource, pos int) {
+	for k, v := range res {
+		if _, ok := def.resources[k]; ok {
+			p.reportf(pos, "resource '%d' is already defined", k)
+			continue
+		}
+
+		def.resources[k] = v
+	}
+}
+
+// mergeLibraryTasks adds tasks of included libraries, their entry points run before the quest's one
+func (def *questDef) mergeLibraryTasks(p *questParser) {
+	if len(def.libraryTasks) == 0 {
+		return
+	}
+
+	if len(def.taskDef) == 0 {
+		def.taskDef = []questTaskDef{{name: "<entry-point>"}}
+	}
+
+	entry := []questCmd{}
+	names := map[string]bool{}
+
+	for _, v := range def.taskDef {
+		names[v.name] = true
+	}
+
+	for _, v := range def.libraryTasks {
+		if v.name == "<entry-point>" {
+			entry = append(entry, v.commands...)
+			continue
+		}
+
+		if names[v.name] {
+			p.reportIn(v.file, v.line, v.pos, "task '%s' from a library is already defined", v.name)
+			continue
+		}
+
+		names[v.name] = true
+		def.taskDef = append(def.taskDef, v)
+	}
+
+	def.taskDef[0].commands = append(entry, def.taskDef[0].commands...)
+	def.libraryTasks = nil
+}
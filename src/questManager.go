@@ -1,27 +1,83 @@
 package main
 
 import (
+	"fmt"
 	"log"
-)
+	"strings"
+	"time"
 
-const (
-	maxQuests = 5
+	"github.com/zaklaus/rurik-prototype/src/telemetry"
+	"github.com/zaklaus/rurik/src/system"
 )
 
 var (
 	stepCounter = 0
 )
 
-type questCommandTable func(qs *quest, qt *questTask, args []string) bool
+// questCommandResult tells the task how to carry on after a command has been executed
+type questCommandResult int
+
+const (
+	// qcContinue moves on to the next command
+	qcContinue questCommandResult = iota
+
+	// qcBlock pauses the task, the command is executed again in the next frame
+	qcBlock
+
+	// qcError means the command has failed, the quest's error policy decides what happens next
+	qcError
+)
+
+// questWaitFor blocks the task until the condition is met
+func questWaitFor(cond bool) questCommandResult {
+	if cond {
+		return qcContinue
+	}
+
+	return qcBlock
+}
+
+type questCommandTable func(qs *quest, qt *questTask, args []string) questCommandResult
 
 type questManager struct {
-	commands map[string]questCommandTable
+	commands map[string]*questCommand
 	quests   []quest
+
+	// commands in the order they were registered, grouped for the command reference
+	commandOrder []string
+	commandGroup string
+
+	// quests added while other quests are being processed,
+	// they are appended once it's safe to do so
+	pendingQuests []quest
+	busy          int
+
+	diagnostics []questDiagnostic
+	events      []questEvent
+
+	// quest pinned to the HUD
+	trackedQuest int64
+
+	// game time used by quest cooldowns
+	time float32
+
+	profile questProfile
+
+	// game seed quests' random generators are derived from
+	seed int64
+}
+
+// questEvent is an event waiting to be delivered to quests
+type questEvent struct {
+	questID  int64
+	template string
+	name     string
+	args     []questVar
 }
 
 func makeQuestManager() questManager {
 	res := questManager{
-		commands: map[string]questCommandTable{},
+		commands: map[string]*questCommand{},
 		quests:   []quest{},
 	}
 
@@ -33,71 +89,108 @@ func makeQuestManager() questManager {
 func (q *questManager) getActiveQuests() []*quest {
 	qs := []*quest{}
 
-	for _, v := range q.quests {
-		if v.state == qsInProgress && !v.runsInBackground {
-			qs = append(qs, &v)
+	for i := range q.quests {
+		if v := &q.quests[i]; v.state == qsInProgress && !v.runsInBackground {
+			qs = append(qs, v)
+		}
+	}
+
+	for i := range q.pendingQuests {
+		if v := &q.pendingQuests[i]; v.state == qsInProgress && !v.runsInBackground {
+			qs = append(qs, v)
 		}
 	}
 
 	return qs
 }
 
-func (q *questManager) addQuest(tplName string, details map[string]float64) (bool, string, int64) {
+func (q *questManager) addQuest(tplName string, details map[string]questVar) (bool, string, int64) {
 	qd := parseQuest(tplName)
 
 	if qd == nil {
 		return false, "Quest template could not be found!", -1
 	}
 
-	if !qd.runsInBackground && len(q.getActiveQuests()) >= maxQuests {
-		return false, "Maximum number of quests has been reached!", -1
+	for _, v := range qd.requires {
+		if !q.isQuestFinished(v) {
+			return false, fmt.Sprintf("Required quest '%s' has not been finished yet!", v), -1
+		}
 	}
 
-	if details == nil {
-		details = map[string]float64{}
+	if ok, reason := q.canStartQuest(tplName, qd); !ok {
+		return false, reason, -1
 	}
 
 	tasks := []questTask{}
 
+	isFirst := true
+
 	for _, v := range qd.taskDef {
+		// sequential quests start with their first task only,
+		// it keeps being dormant when it's marked so
+		if qd.sequential && !v.isEvent && len(tasks) > 0 {
+			if !isFirst {
+				v.isDormant = true
+			}
+
+			isFirst = false
+		}
+
 		tasks = append(tasks, questTask{
 			questTaskDef: v,
 			variables:    map[string]questVar{},
 		})
 	}
 
-	processedDetails := map[string]questVar{}
+	params, err := qd.resolveParams(details)
 
-	for k, v := range details {
-		processedDetails[k] = questVar{
-			kind:  kindNumber,
-			value: &questVarNumber{value: v},
-		}
+	if err != nil {
+		return false, err.Error(), -1
 	}
 
-	tasks[0].variables = processedDetails
+	// the entry point's variables change as the quest runs, restarts need the parameters alone
+	for k, v := range params {
+		tasks[0].variables[k] = v
+	}
 
 	qn := quest{
-		ID:       getNewID(),
-		name:     tplName,
-		questDef: *qd,
-		state:    qsInProgress,
-		timers:   map[string]questTimer{},
-		stages:   map[int]questStage{},
-		tasks:    tasks,
+		ID:               getNewID(),
+		name:             tplName,
+		runsInBackground: qd.runsInBackground,
+		questDef:         *qd,
+		params:           params,
+		state:            qsInProgress,
+		timers:           map[string]questTimer{},
+		stages:           map[int]questStage{},
+		tasks:            tasks,
 	}
 
 	qn.activeQuestTask = &qn.tasks[0]
+	qn.rng = newRandSource(q.seed + qn.ID)
+	qn.logTelemetry(telemetry.KindQuestAdded, telemetry.Event{})
 
 	for _, v := range qn.tasks {
 		qn.setVariable(v.name, 0)
 	}
 
+	q.busy++
+	qn.tasks[0].budget = questCommandBudget
+
 	for qn.processTask(q, &qn.tasks[0]) {
 		// process the whole entry point
 	}
 
-	q.quests = append(q.quests, qn)
+	q.busy--
+
+	if q.busy > 0 {
+		q.pendingQuests = append(q.pendingQuests, qn)
+	} else {
+		q.quests = append(q.quests, qn)
+	}
+
+	if !qn.runsInBackground {
+		q.trackedQuest = qn.ID
+	}
 
 	log.Printf("Quest '%s' with title '%s' has been added!", tplName, qd.title)
 
@@ -106,24 +199,116 @@ func (q *questManager) addQuest(tplName string, details map[string]float64) (boo
 
 func (q *questManager) reset() {
 	q.quests = []quest{}
+	q.pendingQuests = []quest{}
+	q.diagnostics = []questDiagnostic{}
+	q.events = []questEvent{}
+}
+
+func (q *questManager) flushPendingQuests() {
+	if q.busy > 0 || len(q.pendingQuests) == 0 {
+		return
+	}
+
+	q.quests = append(q.quests, q.pendingQuests...)
+	q.pendingQuests = []quest{}
+}
+
+// findQuest looks up a quest by its ID, including the ones added during this frame
+func (q *questManager) findQuest(id int64) *quest {
+	for i := range q.quests {
+		if q.quests[i].ID == id {
+			return &q.quests[i]
+		}
+	}
+
+	for i := range q.pendingQuests {
+		if q.pendingQuests[i].ID == id {
+			return &q.pendingQuests[i]
+		}
+	}
+
+	return nil
+}
+
+// getTrackedQuest returns the quest pinned to the HUD, or the newest active quest when it's no longer active
+func (q *questManager) getTrackedQuest() *quest {
+	qs := q.getActiveQuests()
+
+	for _, v := range qs {
+		if v.ID == q.trackedQuest {
+			return v
+		}
+	}
+
+	if len(qs) == 0 {
+		return nil
+	}
+
+	q.trackedQuest = qs[len(qs)-1].ID
+	return qs[len(qs)-1]
 }
 
-func (q *questManager) registerCommand(name string, cb questCommandTable) {
-	q.commands[name] = cb
+func (q *questManager) isQuestFinished(tplName string) bool {
+	for _, v := range q.quests {
+		if strings.EqualFold(v.name, tplName) && v.state == qsFinished {
+			return true
+		}
+	}
+
+	return false
+}
+
+// registerCommand adds a command with its signature, see questSignatures.go
+func (q *questManager) registerCommand(name, sig, desc string, cb questCommandTable) {
+	if _, ok := q.commands[name]; !ok {
+		q.commandOrder = append(q.commandOrder, name)
+	}
+
+	q.commands[name] = &questCommand{
+		name:    name,
+		group:   q.commandGroup,
+		args:    parseQuestSignature(name, sig),
+		desc:    desc,
+		handler: cb,
+	}
+}
+
+// beginCommandGroup sets the section commands registered next are listed in
+func (q *questManager) beginCommandGroup(name string) {
+	q.commandGroup = name
+}
+
+// dispatchCommand runs a command and records it in the quest profile
+func (q *questManager) dispatchCommand(qs *quest, qt *questTask, name string, args []string) questCommandResult {
+	pc := qt.pc
+	start := time.Now()
+	res := q.runCommand(qs, qt, name, args)
+
+	q.profile.record(qs, qt, pc, res, time.Since(start))
+	return res
 }
 
-func (q *questManager) dispatchCommand(qs *quest, qt *questTask, name string, args []string) (bool, bool) {
+func (q *questManager) runCommand(qs *quest, qt *questTask, name string, args []string) questCommandResult {
 	cmd, ok := q.commands[name]
 
-	if ok {
-		return cmd(qs, qt, args), false
+	if !ok {
+		return questCommandErrorUnknown(name, qs, qt)
 	}
 
-	log.Printf("Quest '%s' has unrecognized command: '%s'!\n", qs.name, name)
-	return false, true
+	if !cmd.acceptsArgs(len(args)) {
+		return questCommandErrorArgRange(name, qs, qt, len(args), cmd.expectedArgs())
+	}
+
+	if res := cmd.checkArgs(qs, qt, args); res != qcContinue {
+		return res
+	}
+
+	return cmd.handler(qs, qt, args)
 }
 
 func (q *questManager) processQuests() {
+	q.busy++
+
 	for i := range q.quests {
 		qs := &q.quests[i]
 
@@ -131,21 +316,118 @@ func (q *questManager) processQuests() {
 			continue
 		}
 
+		qs.resetBudget()
 		qs.processTimers()
 		qs.processTasks(q)
+		qs.checkBudget(q)
 	}
 
+	q.busy--
+	q.flushPendingQuests()
+
+	q.time += system.FrameTime
+	q.profile.frames++
 	stepCounter++
 }
 
-func (q *questManager) callEvent(id int64, eventName string, args []float64) {
+// callEvent queues an event for a quest with the given ID, or all quests when the ID is -1
+func (q *questManager) callEvent(id int64, eventName string, args []questVar) {
+	q.events = append(q.events, questEvent{
+		questID: id,
+		name:    eventName,
+		args:    args,
+	})
+}
+
+// callTemplateEvent queues an event for all running quests made from the template
+func (q *questManager) callTemplateEvent(tplName string, eventName string, args []questVar) {
+	q.events = append(q.events, questEvent{
+		questID:  -1,
+		template: tplName,
+		name:     eventName,
+		args:     args,
+	})
+}
+
+// deliverEvents runs event tasks of all events queued so far,
+// events raised during the delivery are delivered the next time
+func (q *questManager) deliverEvents() {
+	if len(q.events) == 0 {
+		return
+	}
+
+	evnts := q.events
+	q.events = []questEvent{}
+	q.busy++
+
+	for _, ev := range evnts {
+		for i := range q.quests {
+			v := &q.quests[i]
+
+			if v.state != qsInProgress ||
+				(ev.questID != -1 && ev.questID != v.ID) ||
+				(ev.template != "" && !strings.EqualFold(v.name, ev.template)) {
+				continue
+			}
+
+			v.callEvent(q, ev.name, ev.args)
+		}
+	}
+
+	q.busy--
+	q.flushPendingQuests()
+}
+
+// splitVariableScope splits a variable name in form of 'TEMPLATE.var' when the prefix names a quest template
+// of a running quest, otherwise the variable is not scoped and the template name is empty
+func (q *questManager) splitVariableScope(name string) (string, string) {
+	sep := strings.Index(name, ".")
+
+	if sep == -1 {
+		return "", name
+	}
+
+	for _, v := range q.quests {
+		if strings.EqualFold(v.name, name[:sep]) {
+			return v.name, name[sep+1:]
+		}
+	}
+
+	return "", name
+}
+
+// getGlobalVariable looks up a variable of running quests, either scoped by a template name
+// or the first quest declaring it
+func (q *questManager) getGlobalVariable(name string) (float64, bool) {
+	tplName, varName := q.splitVariableScope(name)
+
+	for i := range q.quests {
+		qs := &q.quests[i]
+
+		if qs.state != qsInProgress || (tplName != "" && !strings.EqualFold(qs.name, tplName)) {
+			continue
+		}
+
+		if val, ok := qs.getGlobalVariable(varName); ok {
+			return val, true
+		}
+	}
+
+	return 0, false
+}
+
+// setGlobalVariable sets a variable in running quests, either scoped by a template name
+// or in all of them
+func (q *questManager) setGlobalVariable(name string, val float64) {
+	tplName, varName := q.splitVariableScope(name)
+
 	for i := range q.quests {
-		v := &q.quests[i]
+		qs := &q.quests[i]
 
-		if id != -1 && id != v.ID {
+		if qs.state != qsInProgress || (tplName != "" && !strings.EqualFold(qs.name, tplName)) {
 			continue
 		}
 
-		v.callEvent(q, eventName, args)
+		qs.setGlobalVariable(varName, val)
 	}
 }
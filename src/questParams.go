@@ -0,0 +1,202 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	rl "github.com/zaklaus/raylib-go/raylib"
+)
+
+// questParam is a typed parameter declared in the quest's PARAMS section
+type questParam struct {
+	name     string
+	kind     int
+	value    questVar
+	required bool
+}
+
+var questVarKinds = map[string]int{
+	"number": kindNumber,
+	"vector": kindVector,
+	"string": kindString,
+}
+
+func questVarKindName(kind int) string {
+	for k, v := range questVarKinds {
+		if v == kind {
+			return k
+		}
+	}
+
+	return "unknown"
+}
+
+// parseParams reads parameter declarations in form of: <name> <type> [default]
+func (p *questParser) parseParams() (res []questParam) {
+	res = []questParam{}
+	block := p.nextTextBlock()
+
+	for _, line := range strings.Split(block, "\n") {
+		fields := strings.Fields(line)
+
+		if len(fields) == 0 {
+			continue
+		}
+
+		if len(fields) < 2 {
+			p.fatalf(p.lastWordPos, "Parameter '%s' has no type specified!", fields[0])
+			return
+		}
+
+		kind, ok := questVarKinds[strings.ToLower(fields[1])]
+
+		if !ok {
+			p.fatalf(p.lastWordPos, "Parameter '%s' has an unknown type '%s'!", fields[0], fields[1])
+			return
+		}
+
+		param := questParam{
+			name:     fields[0],
+			kind:     kind,
+			required: len(fields) == 2,
+		}
+
+		if !param.required {
+			val, err := parseQuestVar(kind, fields[2:])
+
+			if err != nil {
+				p.fatalf(p.lastWordPos, "Parameter '%s' has an invalid default value: %s", fields[0], err.Error())
+				return
+			}
+
+			param.value = val
+		}
+
+		res = append(res, param)
+	}
+
+	return
+}
+
+func parseQuestVar(kind int, fields []string) (questVar, error) {
+	switch kind {
+	case kindNumber:
+		if len(fields) != 1 {
+			return questVar{}, fmt.Errorf("a single number expected")
+		}
+
+		num, err := strconv.ParseFloat(fields[0], 64)
+
+		if err != nil {
+			return questVar{}, fmt.Errorf("'%s' is not a number", fields[0])
+		}
+
+		return makeQuestVarNumber(num), nil
+
+	case kindVector:
+		if len(fields) != 2 {
+			return questVar{}, fmt.Errorf("two numbers expected")
+		}
+
+		x, errX := strconv.ParseFloat(fields[0], 32)
+		y, errY := strconv.ParseFloat(fields[1], 32)
+
+		if errX != nil || errY != nil {
+			return questVar{}, fmt.Errorf("'%s %s' is not a vector", fields[0], fields[1])
+		}
+
+		return makeQuestVarVector(rl.NewVector2(float32(x), float32(y))), nil
+	}
+
+	return makeQuestVarString(strings.Join(fields, " ")), nil
+}
+
+// toQuestVar converts a value passed by scripts into a quest variable
+func toQuestVar(v interface{}) (questVar, bool) {
+	switch n := v.(type) {
+	case float64:
+		return makeQuestVarNumber(n), true
+	case int64:
+		return makeQuestVarNumber(float64(n)), true
+	case int:
+		return makeQuestVarNumber(float64(n)), true
+	case string:
+		return makeQuestVarString(n), true
+	case []interface{}:
+		vec, ok := toVector(n)
+
+		if !ok {
+			return questVar{}, false
+		}
+
+		return makeQuestVarVector(vec), true
+	}
+
+	return questVar{}, false
+}
+
+func makeQuestVarNumber(val float64) questVar {
+	return questVar{
+		kind:  kindNumber,
+		value: &questVarNumber{value: val},
+	}
+}
+
+func makeQuestVarVector(val rl.Vector2) questVar {
+	return questVar{
+		kind:  kindVector,
+		value: &questVarVector{value: val},
+	}
+}
+
+func makeQuestVarString(val string) questVar {
+	return questVar{
+		kind:  kindString,
+		value: &questVarString{value: val},
+	}
+}
+
+// resolveParams checks the passed parameters against the declared ones and fills in the defaults,
+// quests without the PARAMS section accept any parameters
+func (qd *questDef) resolveParams(details map[string]questVar) (map[string]questVar, error) {
+	res := map[string]questVar{}
+
+	if qd.params == nil {
+		for k, v := range details {
+			res[k] = v
+		}
+
+		return res, nil
+	}
+
+	declared := map[string]bool{}
+
+	for _, p := range qd.params {
+		declared[p.name] = true
+		val, ok := details[p.name]
+
+		if !ok {
+			if p.required {
+				return nil, fmt.Errorf("Quest parameter '%s' is missing", p.name)
+			}
+
+			val = p.value
+		}
+
+		if val.kind != p.kind {
+			return nil, fmt.Errorf("Quest parameter '%s' has to be of type '%s', got '%s'",
+				p.name, questVarKindName(p.kind), questVarKindName(val.kind))
+		}
+
+		res[p.name] = val
+	}
+
+	for k := range details {
+		if !declared[k] {
+			return nil, fmt.Errorf("Quest parameter '%s' is not declared", k)
+		}
+	}
+
+	return res, nil
+}
@@ -3,24 +3,45 @@ package main
 /*
 	Quest language parser
 
-	WARN: The parser is not Unicode-aware! Use ASCII characters only!
+	Quest files are expected to be UTF-8 encoded. The parser works on runes,
+	however all positions (textPos, wordPos) are byte offsets into the file.
 */
 
 import (
+	"bytes"
 	"fmt"
 	"log"
 	"strconv"
 	"strings"
 	"unicode"
-
-	"github.com/zaklaus/rurik/src/system"
+	"unicode/utf8"
 )
 
 // Quest language keywords
 const (
 	kwTitle      = "title"
 	kwBackground = "+background"
+	kwRunaway    = "+failonrunaway"
+	kwOnError    = "+onerror"
+	kwSequential = "+sequential"
+	kwDormant    = "dormant"
+	kwOptional   = "optional"
+	kwHidden     = "hidden"
+	kwTarget     = "target"
+	kwOrder      = "order"
+	kwWaypoint   = "waypoint"
+	kwTimer      = "timer"
 	kwBriefing   = "briefing"
+	kwRequires   = "requires"
+	kwInclude    = "include"
+	kwConstants  = "def"
+	kwMacro      = "macro"
+	kwEnd        = "end"
+	kwCategory   = "category"
+	kwUnique     = "+unique"
+	kwRepeatable = "+repeatable"
+	kwCooldown   = "cooldown="
+	kwParams     = "params"
 	kwResources  = "qrc"
 	kwMessage    = "message"
 	kwVideo      = "video"
@@ -29,6 +50,9 @@ const (
 	kwStages     = "qst"
 	kwTask       = "task"
 	kwEvent      = "event"
+	kwErrorTask  = "on_error"
+	kwTimeout    = "timeout"
+	kwGoto       = "goto"
 	kwSet        = "set"
 	kwAbove      = "above"
 	kwBelow      = "below"
@@ -64,18 +88,45 @@ type questTaskDef struct {
 	pc       int
 	isDone   bool
 
+	// dormant tasks don't run until they're started
+	isDormant bool
+
+	// time spent blocked by the current command
+	waitTime float32
+
 	isEvent   bool
-	eventArgs []float64
+	eventArgs []questVar
+
+	// where the task is declared, tasks of libraries are reported there
+	file string
+	line int
+	pos  int
 }
 
 type questCmd struct {
 	name string
 	args []string
+
+	// optional 'timeout <seconds> goto <label|task>' suffix of blocking commands
+	timeout string
+	target  string
+
+	// byte offset of the command in its file, macros use the position of their call
+	pos  int
+	file string
 }
 
 type questResource struct {
 	kind    int
 	content string
+
+	// stage attributes in form of 'STAGE: <id> (optional, hidden, target=N, order=N, waypoint=X, timer=X)'
+	order    int
+	optional bool
+	hidden   bool
+	target   int
+	waypoint string
+	timer    string
 }
 
 const (
@@ -85,6 +136,23 @@ const (
 	qrStage
 )
 
+// Error policies
+const (
+	epHalt = iota
+	epSkip
+	epFail
+)
+
+var questErrorPolicies = map[string]int{
+	"halt": epHalt,
+	"skip": epSkip,
+	"fail": epFail,
+}
+
+const (
+	questErrorHandler = "ON_ERROR"
+)
+
 var questResourceKinds = map[string]int{
 	kwMessage: qrMessage,
 	kwSound:   qrSound,
@@ -97,15 +165,60 @@ type questParser struct {
 	textPos         int
 	lastWordPos     int
 	allowWhitespace bool
+
+	file        string
+	diagnostics *[]questParseDiagnostic
+	macros      map[string]questMacro
+
+	// constants and resource names shared with included libraries
+	symbols       map[string]string
+	resourceNames map[string]int
+
+	// chain of libraries being included, used to detect recursive includes
+	includes []string
+	included map[string]bool
+
+	// editor mode, syntax errors are reported as diagnostics instead of stopping the game
+	recoverable bool
+	readAsset   func(name string) []byte
+	decls       *[]questDecl
+
+	// permission of the quest being parsed, it limits events the quest can invoke
+	permission int
 }
 
-func (p *questParser) at(idx int) rune {
-	return rune(p.data[idx])
+// at decodes a rune at the byte offset and returns its size in bytes
+func (p *questParser) at(idx int) (rune, int) {
+	return utf8.DecodeRune(p.data[idx:])
+}
+
+// checkEncoding reports the first invalid UTF-8 sequence and skips the byte order mark
+func (p *questParser) checkEncoding() {
+	if bytes.HasPrefix(p.data, utf8BOM) {
+		p.textPos = len(utf8BOM)
+	}
+
+	for idx := p.textPos; idx < len(p.data); {
+		r, size := utf8.DecodeRune(p.data[idx:])
+
+		if r == utf8.RuneError && size == 1 {
+			p.reportf(idx, "invalid UTF-8 sequence at byte offset '%d'", idx)
+			return
+		}
+
+		idx += size
+	}
 }
 
 func (p *questParser) skipWhitespace() {
-	for p.textPos < len(p.data) && (isWhitespace(p.at(p.textPos))) {
-		p.textPos++
+	for p.textPos < len(p.data) {
+		r, size := p.at(p.textPos)
+
+		if !isWhitespace(r) {
+			break
+		}
+
+		p.textPos += size
 	}
 }
 
@@ -120,12 +233,18 @@ func (p *questParser) peekChar() rune {
 		return 0
 	}
 
-	return p.at(p.textPos)
+	r, _ := p.at(p.textPos)
+	return r
 }
 
 func (p *questParser) nextChar() rune {
-	r := p.peekChar()
-	p.textPos++
+	if p.textPos >= len(p.data)-1 {
+		p.textPos++
+		return 0
+	}
+
+	r, size := p.at(p.textPos)
+	p.textPos += size
 
 	return r
 }
@@ -201,7 +320,7 @@ func (p *questParser) nextIdentifier() string {
 	ident := p.parseToken()
 
 	if ident.kind != tkIdentifier {
-		log.Fatalf("Token at '%d' invalid! Expected Identifier.\n", ident.wordPos)
+		p.fatalf(ident.wordPos, "Token at '%d' invalid! Expected Identifier.", ident.wordPos)
 		return ""
 	}
 
@@ -213,7 +332,7 @@ func (p *questParser) nextWord() string {
 	t := p.parseToken()
 
 	if t.kind != tkIdentifier && t.kind != tkInteger {
-		log.Fatalf("Word at '%d' invalid! Expected Word.\n", t.wordPos)
+		p.fatalf(t.wordPos, "Word at '%d' invalid! Expected Word.", t.wordPos)
 		return ""
 	}
 
@@ -259,7 +378,7 @@ func (p *questParser) nextNumber() int {
 	tk := p.parseToken()
 
 	if tk.kind != tkInteger {
-		log.Fatalf("Number at '%d' invalid! Expected Number.\n", tk.wordPos)
+		p.fatalf(tk.wordPos, "Number at '%d' invalid! Expected Number.", tk.wordPos)
 		return -1
 	}
 
@@ -273,7 +392,7 @@ func (p *questParser) expect(ident string) bool {
 	tk := p.parseToken()
 
 	if tk.kind != tkIdentifier || strings.ToLower(tk.text) != ident {
-		log.Fatalf("Unexpected token '%v'! Expected: '%s'.\n", tk, ident)
+		p.fatalf(tk.wordPos, "Unexpected token '%s'! Expected: '%s'.", tk.text, ident)
 		ok = false
 	}
 
@@ -302,21 +421,73 @@ func (p *questParser) parseResources() (res map[int]questResource) {
 	for resKind := p.peekToken(); resKind.kind != tkEndOfFile && p.checkResourceKind(resKind.text); resKind = p.peekToken() {
 		p.parseToken()
 		p.expect(kwScope)
-		resourceID := p.nextNumber()
+		resourceName := p.nextWord()
+		namePos := p.lastWordPos
+		resourceID := p.resourceID(resourceName, resKind.wordPos)
 		kind, _ := questResourceKinds[strings.ToLower(resKind.text)]
-		content := p.nextTextBlock()
 
-		res[resourceID] = questResource{
-			kind:    kind,
-			content: content,
+		qr := questResource{
+			kind:  kind,
+			order: resourceID,
+		}
+
+		if attrs := p.peekToken(); strings.HasPrefix(attrs.text, kwLeftBrace) {
+			p.parseToken()
+			p.parseResourceAttributes(&qr, attrs)
 		}
 
+		qr.content = p.nextTextBlock()
+		res[resourceID] = qr
+		p.declare(declResource, resourceName, namePos, qr.content)
+
 		p.skipSeparators()
 	}
 
 	return
 }
 
+func (p *questParser) parseResourceAttributes(res *questResource, attrs questToken) {
+	list := strings.Trim(attrs.text, kwLeftBrace+kwRightBrace)
+
+	for _, v := range strings.FieldsFunc(list, func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	}) {
+		kv := strings.SplitN(v, "=", 2)
+		key, val := strings.ToLower(kv[0]), ""
+
+		if len(kv) == 2 {
+			val = kv[1]
+		}
+
+		num := func() int {
+			n, err := strconv.Atoi(val)
+
+			if err != nil {
+				p.fatalf(attrs.wordPos, "Resource attribute '%s' at '%d' expects a number!", key, attrs.wordPos)
+			}
+
+			return n
+		}
+
+		switch key {
+		case kwOptional:
+			res.optional = true
+		case kwHidden:
+			res.hidden = true
+		case kwTarget:
+			res.target = num()
+		case kwOrder:
+			res.order = num()
+		case kwWaypoint:
+			res.waypoint = val
+		case kwTimer:
+			res.timer = val
+		default:
+			p.fatalf(attrs.wordPos, "Unknown resource attribute '%s' at '%d'!", v, attrs.wordPos)
+		}
+	}
+}
+
 func (p *questParser) parseTasks() (res []questTaskDef) {
 	res = []questTaskDef{}
 
@@ -331,19 +502,46 @@ func (p *questParser) parseTasks() (res []questTaskDef) {
 	for t := p.peekToken(); t.kind == tkIdentifier; t = p.peekToken() {
 		kw := strings.ToLower(p.nextIdentifier())
 
+		if kw == kwErrorTask {
+			p.declare(declEvent, questErrorHandler, t.wordPos, "")
+			p.expect(kwScope)
+
+			res = append(res, questTaskDef{
+				name:     questErrorHandler,
+				commands: p.parseTask(),
+				isEvent:  true,
+			})
+
+			log.Printf("Error handler has been added!")
+
+			p.skipSeparators()
+			continue
+		}
+
 		if kw != kwTask && kw != kwEvent {
-			log.Fatalf("Invalid task found at '%d'!\n", t.wordPos)
+			p.fatalf(t.wordPos, "Invalid task found at '%d'!", t.wordPos)
 			return
 		}
 
 		taskName := p.nextIdentifier()
-		p.expect(kwScope)
+		pos := p.lastWordPos
+		p.declare(questDeclKinds[kw], taskName, pos, "")
+		task := questTaskDef{
+			name:    taskName,
+			isEvent: kw == kwEvent,
+			file:    p.file,
+			line:    p.lineAt(pos),
+			pos:     pos,
+		}
 
-		res = append(res, questTaskDef{
-			name:     taskName,
-			commands: p.parseTask(),
-			isEvent:  kw == kwEvent,
-		})
+		if attrs := p.peekToken(); strings.HasPrefix(attrs.text, kwLeftBrace) {
+			p.parseToken()
+			p.parseTaskAttributes(&task, attrs)
+		}
+
+		p.expect(kwScope)
+		task.commands = p.parseTask()
+		res = append(res, task)
 
 		taskType := "Task"
 
@@ -359,13 +557,29 @@ func (p *questParser) parseTasks() (res []questTaskDef) {
 	return
 }
 
+// parseTaskAttributes handles attributes in form of 'task X (attr, ...):'
+func (p *questParser) parseTaskAttributes(task *questTaskDef, attrs questToken) {
+	list := strings.Trim(attrs.text, kwLeftBrace+kwRightBrace)
+
+	for _, v := range strings.FieldsFunc(list, func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	}) {
+		switch strings.ToLower(v) {
+		case kwDormant:
+			task.isDormant = true
+		default:
+			p.fatalf(attrs.wordPos, "Task '%s' has an unknown attribute '%s' at '%d'!", task.name, v, attrs.wordPos)
+		}
+	}
+}
+
 func (p *questParser) parseTask() (res []questCmd) {
 	res = []questCmd{}
 	p.skipSeparators()
 
 	for t := p.peekToken(); t.kind == tkIdentifier; t = p.peekToken() {
 		// end of the line
-		if t.text == kwTask || t.text == kwEvent {
+		if t.text == kwTask || t.text == kwEvent || strings.ToLower(t.text) == kwErrorTask {
 			break
 		}
 
@@ -377,10 +591,22 @@ func (p *questParser) parseTask() (res []questCmd) {
 			args = append(args, p.nextWord())
 		}
 
-		res = append(res, questCmd{
-			name: cmd,
-			args: args,
-		})
+		if m, ok := p.macros[cmd]; ok {
+			for _, v := range p.expandMacro(m, args, t.wordPos, 0) {
+				v.pos = t.wordPos
+				v.file = p.file
+				v = p.resolveSymbols(v)
+				p.checkInvoke(v, t.wordPos)
+				res = append(res, v)
+			}
+		} else {
+			qc := makeQuestCmd(cmd, args)
+			qc.pos = t.wordPos
+			qc.file = p.file
+			qc = p.resolveSymbols(qc)
+			p.checkInvoke(qc, t.wordPos)
+			res = append(res, qc)
+		}
 
 		p.skipSeparators()
 	}
@@ -388,24 +614,63 @@ func (p *questParser) parseTask() (res []questCmd) {
 	return
 }
 
+func makeQuestCmd(cmd string, args []string) questCmd {
+	qc := questCmd{
+		name: cmd,
+		args: args,
+	}
+
+	if n := len(args); n >= 4 && strings.ToLower(args[n-4]) == kwTimeout && strings.ToLower(args[n-2]) == kwGoto {
+		qc.timeout = args[n-3]
+		qc.target = args[n-1]
+		qc.args = args[:n-4]
+	}
+
+	return qc
+}
+
 // questDef describes the quest definition file and the opcodes
 type questDef struct {
 	title            string
 	briefing         string
 	runsInBackground bool
+	failOnRunaway    bool
+	onError          int
+	sequential       bool
+	category         int
+	unique           bool
+	repeatable       bool
+	cooldown         float32
+	requires         []string
+	params           []questParam
 	resources        map[int]questResource
+	resourceNames    map[string]int
 	taskDef          []questTaskDef
+
+	// tasks pulled from included libraries
+	libraryTasks []questTaskDef
+
+	// modded quests can only invoke a subset of core events
+	permission int
 }
 
 var (
 	questCache = map[string]*questDef{}
+	utf8BOM    = []byte{0xEF, 0xBB, 0xBF}
 )
 
 func parseQuest(questName string) *questDef {
-	questAsset := system.FindAsset(fmt.Sprintf("quests/%s.qst", strings.ToLower(questName)))
+	fileName := fmt.Sprintf("quests/%s.qst", strings.ToLower(questName))
+	data := readAsset(fileName)
+
+	// quests of the game take precedence over modded ones
+	if data == nil {
+		fileName = fmt.Sprintf("%s/quests/%s.qst", questModsDir, strings.ToLower(questName))
+		data = readAsset(fileName)
+	}
 
-	if questAsset == nil {
-		log.Fatalf("Quest '%s' could not be found!\n", questName)
+	if data == nil {
+		log.Printf("Quest '%s' could not be found!\n", questName)
 		return nil
 	}
 
@@ -416,47 +681,142 @@ func parseQuest(questName string) *questDef {
 		return cachedQuest
 	} */
 
+	diags := []questParseDiagnostic{}
 	parser := questParser{
-		data: questAsset.Data,
+		data:          data,
+		file:          fileName,
+		diagnostics:   &diags,
+		macros:        map[string]questMacro{},
+		symbols:       map[string]string{},
+		resourceNames: map[string]int{},
+		included:      map[string]bool{},
+		readAsset:     readAsset,
+		permission:    questFilePermission(fileName),
+	}
+
+	def := &questDef{
+		category:   catSide,
+		resources:  map[int]questResource{},
+		permission: parser.permission,
+	}
+
+	parser.checkEncoding()
+	parser.parseDefinition(def, false)
+	def.mergeLibraryTasks(&parser)
+	def.resourceNames = parser.resourceNames
+
+	for _, v := range diags {
+		log.Printf("Quest '%s': %s", questName, v.String())
 	}
 
-	def := &questDef{}
+	questCache[questName] = def
+
+	return def
+}
+
+// parseDefinition parses the quest file's sections, libraries can't use the quest header
+func (p *questParser) parseDefinition(def *questDef, isLibrary bool) {
+	for t := p.peekToken(); t.kind != tkEndOfFile; t = p.peekToken() {
+		p.skipSeparators()
+		ident := p.nextIdentifier()
+		pos := p.lastWordPos
 
-	for t := parser.peekToken(); t.kind != tkEndOfFile; t = parser.peekToken() {
-		parser.skipSeparators()
-		ident := parser.nextIdentifier()
+		if strings.ToLower(ident) == kwMacro {
+			p.parseMacro(pos)
+			continue
+		}
 
 		if ident[0] == '+' {
-			parser.handleFlag(def, ident)
+			if isLibrary {
+				p.reportf(pos, "flag '%s' can't be used in a library", ident)
+			}
+
+			p.handleFlag(def, ident)
 			continue
 		}
 
-		parser.expect(kwScope)
+		p.expect(kwScope)
+		kw := strings.ToLower(ident)
+
+		if isLibrary && kw != kwInclude && kw != kwResources && kw != kwStages && kw != kwConstants {
+			p.reportf(pos, "section '%s' can't be used in a library", ident)
+		}
 
-		switch strings.ToLower(ident) {
+		switch kw {
+		case kwInclude:
+			for _, v := range strings.FieldsFunc(p.nextString(), func(r rune) bool {
+				return r == ',' || unicode.IsSpace(r)
+			}) {
+				p.include(def, v, pos)
+			}
 		case kwTitle:
-			def.title = parser.nextString()
+			def.title = p.nextString()
 		case kwBriefing:
-			def.briefing = parser.nextTextBlock()
+			def.briefing = p.nextTextBlock()
+		case kwRequires:
+			def.requires = strings.FieldsFunc(p.nextString(), func(r rune) bool {
+				return r == ',' || unicode.IsSpace(r)
+			})
+		case kwCategory:
+			cat, ok := questCategories[strings.ToLower(p.nextWord())]
+
+			if !ok {
+				p.fatalf(t.wordPos, "Unknown quest category at '%d'! Expected: 'main', 'side', 'task' or 'background'.", t.wordPos)
+				return
+			}
+
+			def.category = cat
+			def.runsInBackground = cat == catBackground
+		case kwParams:
+			def.params = p.parseParams()
+		case kwConstants:
+			p.parseConstants()
 		case kwResources:
-			def.resources = parser.parseResources()
+			p.mergeResources(def, p.parseResources(), pos)
 		case kwStages:
-			def.taskDef = parser.parseTasks()
+			def.taskDef = p.parseTasks()
 		default:
-			log.Fatalf("Undefined token at '%d'! It says: '%s'.\n", t.wordPos, ident)
-			return def
+			p.fatalf(pos, "Undefined token at '%d'! It says: '%s'.", pos, ident)
+			return
 		}
 	}
-
-	questCache[questName] = def
-
-	return def
 }
 
 func (p *questParser) handleFlag(def *questDef, flag string) {
-	switch flag {
+	switch strings.ToLower(flag) {
 	case kwBackground:
 		def.runsInBackground = true
+		def.category = catBackground
+	case kwUnique:
+		def.unique = true
+	case kwRepeatable:
+		def.repeatable = true
+
+		if cd := p.peekToken(); strings.HasPrefix(strings.ToLower(cd.text), kwCooldown) {
+			p.parseToken()
+			val, err := strconv.ParseFloat(cd.text[len(kwCooldown):], 32)
+
+			if err != nil {
+				p.fatalf(cd.wordPos, "Cooldown at '%d' expects a number!", cd.wordPos)
+				return
+			}
+
+			def.cooldown = float32(val)
+		}
+	case kwRunaway:
+		def.failOnRunaway = true
+	case kwSequential:
+		def.sequential = true
+	case kwOnError:
+		policy := strings.ToLower(p.nextWord())
+		val, ok := questErrorPolicies[policy]
+
+		if !ok {
+			p.fatalf(p.lastWordPos, "Unknown error policy '%s'! Expected: 'fail', 'skip' or 'halt'.", policy)
+			return
+		}
+
+		def.onError = val
 	}
 }
 
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// parseQuestSource parses a quest from memory and returns the diagnostics it has reported
+func parseQuestSource(src string) (*questDef, []questParseDiagnostic) {
+	diags := []questParseDiagnostic{}
+	parser := questParser{
+		data:          []byte(src),
+		file:          "quests/test.qst",
+		diagnostics:   &diags,
+		macros:        map[string]questMacro{},
+		symbols:       map[string]string{},
+		resourceNames: map[string]int{},
+		included:      map[string]bool{},
+		readAsset:     func(name string) []byte { return nil },
+		permission:    permBase,
+	}
+
+	def := &questDef{
+		category:   catSide,
+		resources:  map[int]questResource{},
+		permission: parser.permission,
+	}
+
+	parser.checkEncoding()
+	parser.parseDefinition(def, false)
+	def.resourceNames = parser.resourceNames
+
+	return def, diags
+}
+
+func TestQuestParserUnicodeText(t *testing.T) {
+	def, diags := parseQuestSource(`TITLE: Příliš žluťoučký kůň — Съешь же ещё
+BRIEFING: Úkol s textem v češtině a ruštině.
+Вторая строка брифинга — «ёжик» и „uvozovky“.
+
+QRC:
+
+MESSAGE: pozdrav
+Ahoj, poutníku! Здравствуй, путник!
+
+MESSAGE: 1010
+Čeká tě dlouhá cesta… Долгий путь ждёт тебя.
+
+STAGE: úkol (optional)
+Najdi ztracený meč — найди потерянный меч
+
+QST:
+
+say pozdrav
+stage úkol
+`)
+
+	if len(diags) > 0 {
+		t.Fatalf("got diagnostics: %v", diags)
+	}
+
+	if want := "Příliš žluťoučký kůň — Съешь же ещё"; def.title != want {
+		t.Errorf("got title '%s', want '%s'", def.title, want)
+	}
+
+	if want := "Úkol s textem v češtině a ruštině.\nВторая строка брифинга — «ёжик» и „uvozovky“."; def.briefing != want {
+		t.Errorf("got briefing '%s', want '%s'", def.briefing, want)
+	}
+
+	messages := map[string]string{
+		"pozdrav": "Ahoj, poutníku! Здравствуй, путник!",
+		"1010":    "Čeká tě dlouhá cesta… Долгий путь ждёт тебя.",
+		"úkol":    "Najdi ztracený meč — найди потерянный меч",
+	}
+
+	qs := &quest{questDef: *def}
+
+	for name, want := range messages {
+		res, ok := qs.getResource(name)
+
+		if !ok {
+			t.Errorf("resource '%s' could not be found", name)
+			continue
+		}
+
+		if res.content != want {
+			t.Errorf("got resource '%s' = '%s', want '%s'", name, res.content, want)
+		}
+	}
+
+	// named resources are resolved to their IDs
+	stageID := fmt.Sprint(def.resourceNames["úkol"])
+
+	if cmds := def.taskDef[0].commands; len(cmds) != 2 || cmds[1].args[0] != stageID {
+		t.Errorf("got commands %+v, want 'stage %s' to be the second one", cmds, stageID)
+	}
+}
+
+func TestQuestParserSkipsBOM(t *testing.T) {
+	def, diags := parseQuestSource("\xEF\xBB\xBFTITLE: Úkol\n\nQST:\n\nfinish\n")
+
+	if len(diags) > 0 {
+		t.Fatalf("got diagnostics: %v", diags)
+	}
+
+	if def.title != "Úkol" {
+		t.Errorf("got title '%s', want 'Úkol'", def.title)
+	}
+}
+
+func TestQuestParserInvalidUTF8(t *testing.T) {
+	src := "TITLE: Úkol\nBRIEFING: Špatný znak \xff tady\n\nQST:\n\nfinish\n"
+	offset := strings.IndexByte(src, 0xff)
+
+	_, diags := parseQuestSource(src)
+
+	if len(diags) != 1 {
+		t.Fatalf("got %d diagnostics, want 1: %v", len(diags), diags)
+	}
+
+	d := diags[0]
+
+	if d.pos != offset || d.line != 2 {
+		t.Errorf("got diagnostic at byte %d on line %d, want byte %d on line 2", d.pos, d.line, offset)
+	}
+
+	if want := fmt.Sprintf("byte offset '%d'", offset); !strings.Contains(d.message, want) {
+		t.Errorf("got message '%s', want it to mention %s", d.message, want)
+	}
+}
+
+func TestQuestParserExpandsMacros(t *testing.T) {
+	def, diags := parseQuestSource(`TITLE: Macros
+
+macro hunt(target, count):
+    setvar count_target count
+    log str Hunting target with counter
+end
+
+QST:
+
+hunt wolves 5
+hunt bears 2
+`)
+
+	if len(diags) > 0 {
+		t.Fatalf("got diagnostics: %v", diags)
+	}
+
+	want := [][]string{
+		{"count_target", "5"},
+		{"str", "Hunting", "wolves", "with", "counter"},
+		{"count_target", "2"},
+		{"str", "Hunting", "bears", "with", "counter"},
+	}
+
+	cmds := def.taskDef[0].commands
+
+	if len(cmds) != len(want) {
+		t.Fatalf("got %d commands, want %d: %+v", len(cmds), len(want), cmds)
+	}
+
+	for i, v := range cmds {
+		if strings.Join(v.args, " ") != strings.Join(want[i], " ") {
+			t.Errorf("got command %d args %v, want %v", i, v.args, want[i])
+		}
+	}
+}
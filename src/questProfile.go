@@ -0,0 +1,294 @@
+package main
+
+/*
+	Quest profiling and coverage
+
+	Every dispatched command is counted per quest template, task and command index,
+	along with the time it took and how often it blocked its task. A blocked command
+	holds its task for the rest of the frame, so blocks also count frames spent waiting.
+
+	The coverage report maps the counts back to lines of the quest files,
+	it's written once the game quits when started with '-questcoverage <file>'.
+	The per-quest CPU table is toggled by F7 in the debug mode.
+*/
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	rl "github.com/zaklaus/raylib-go/raylib"
+	"github.com/zaklaus/rurik/src/system"
+)
+
+const (
+	questProfileX       = 320
+	questProfileY       = 60
+	maxQuestProfileRows = 16
+)
+
+var (
+	questCoverageFile string
+	showQuestProfile  bool
+)
+
+type questProfileKey struct {
+	quest string
+	task  string
+	pc    int
+}
+
+type questProfileEntry struct {
+	runs   int
+	blocks int
+	errors int
+	time   time.Duration
+}
+
+type questProfile struct {
+	entries map[questProfileKey]*questProfileEntry
+
+	// frames processed since the profiling began
+	frames int
+}
+
+// questProfileSummary holds totals of a single quest template or task
+type questProfileSummary struct {
+	name   string
+	runs   int
+	blocks int
+	errors int
+	time   time.Duration
+}
+
+func (p *questProfile) record(qs *quest, qt *questTask, pc int, res questCommandResult, d time.Duration) {
+	if p.entries == nil {
+		p.entries = map[questProfileKey]*questProfileEntry{}
+	}
+
+	key := questProfileKey{
+		quest: qs.name,
+		task:  qt.name,
+		pc:    pc,
+	}
+
+	e, ok := p.entries[key]
+
+	if !ok {
+		e = &questProfileEntry{}
+		p.entries[key] = e
+	}
+
+	e.runs++
+	e.time += d
+
+	switch res {
+	case qcBlock:
+		e.blocks++
+	case qcError:
+		e.errors++
+	}
+}
+
+// summarize sums up entries by the name the given function returns, the most expensive come first
+func (p *questProfile) summarize(name func(k questProfileKey) string) []questProfileSummary {
+	byName := map[string]*questProfileSummary{}
+	res := []questProfileSummary{}
+
+	for k, v := range p.entries {
+		n := name(k)
+		s, ok := byName[n]
+
+		if !ok {
+			s = &questProfileSummary{name: n}
+			byName[n] = s
+		}
+
+		s.runs += v.runs
+		s.blocks += v.blocks
+		s.errors += v.errors
+		s.time += v.time
+	}
+
+	for _, v := range byName {
+		res = append(res, *v)
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].time != res[j].time {
+			return res[i].time > res[j].time
+		}
+
+		return res[i].name < res[j].name
+	})
+
+	return res
+}
+
+// writeCoverageReport writes the coverage of all quests that were loaded
+func (p *questProfile) writeCoverageReport(path string) {
+	var buf bytes.Buffer
+	p.coverageReport(&buf)
+
+	if err := ioutil.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		log.Printf("Quest coverage could not be written: %s\n", err)
+		return
+	}
+
+	log.Printf("Quest coverage has been written to '%s'\n", path)
+}
+
+// questCoverageLine holds counts of commands on a single source line
+type questCoverageLine struct {
+	covered int
+	questProfileEntry
+}
+
+func (p *questProfile) coverageReport(w io.Writer) {
+	names := []string{}
+
+	for k := range questCache {
+		names = append(names, k)
+	}
+
+	sort.Strings(names)
+	fmt.Fprintf(w, "Quest coverage after %d frames\n", p.frames)
+
+	for _, name := range names {
+		def := questCache[name]
+		files := []string{}
+		sources := map[string][]byte{}
+		lines := map[string]map[int]*questCoverageLine{}
+		total, covered := 0, 0
+
+		for _, t := range def.taskDef {
+			for pc, c := range t.commands {
+				if c.file == "" {
+					continue
+				}
+
+				if _, ok := lines[c.file]; !ok {
+					files = append(files, c.file)
+					sources[c.file] = readAsset(c.file)
+					lines[c.file] = map[int]*questCoverageLine{}
+				}
+
+				data := sources[c.file]
+				line := strings.Count(string(data[:clampInt(c.pos, 0, len(data))]), "\n") + 1
+				l, ok := lines[c.file][line]
+
+				if !ok {
+					l = &questCoverageLine{}
+					lines[c.file][line] = l
+				}
+
+				total++
+
+				if e, ok := p.entries[questProfileKey{quest: name, task: t.name, pc: pc}]; ok && e.runs > 0 {
+					l.covered++
+					covered++
+					l.runs += e.runs
+					l.blocks += e.blocks
+					l.errors += e.errors
+					l.time += e.time
+				}
+			}
+		}
+
+		percent := 0.0
+
+		if total > 0 {
+			percent = float64(covered) * 100 / float64(total)
+		}
+
+		fmt.Fprintf(w, "\nQuest '%s': %d of %d commands run (%.1f%%)\n", name, covered, total, percent)
+
+		for _, file := range files {
+			fmt.Fprintf(w, "\n%s\n%9s %9s %6s %10s | line\n", file, "runs", "blocked", "errors", "time ms")
+
+			for i, text := range strings.Split(strings.TrimRight(string(sources[file]), "\r\n"), "\n") {
+				text = strings.TrimRight(text, "\r")
+				l, ok := lines[file][i+1]
+
+				switch {
+				case !ok:
+					fmt.Fprintf(w, "%9s %9s %6s %10s | %4d: %s\n", "-", "", "", "", i+1, text)
+				case l.covered == 0:
+					fmt.Fprintf(w, "%9s %9s %6s %10s | %4d: %s\n", "#####", "", "", "", i+1, text)
+				default:
+					fmt.Fprintf(w, "%9d %9d %6d %10.3f | %4d: %s\n", l.runs, l.blocks, l.errors, l.time.Seconds()*1000, i+1, text)
+				}
+			}
+		}
+
+		fmt.Fprintf(w, "\nTasks of '%s':\n", name)
+
+		for _, t := range def.taskDef {
+			s := questProfileSummary{}
+
+			for k, v := range p.entries {
+				if k.quest == name && k.task == t.name {
+					s.runs += v.runs
+					s.blocks += v.blocks
+					s.time += v.time
+				}
+			}
+
+			fmt.Fprintf(w, "    %s: %d commands run, blocked for %d frames, %.3f ms\n", t.name, s.runs, s.blocks, s.time.Seconds()*1000)
+		}
+	}
+}
+
+func clampInt(v, min, max int) int {
+	if v < min {
+		return min
+	}
+
+	if v > max {
+		return max
+	}
+
+	return v
+}
+
+func updateQuestProfile() {
+	if rl.IsKeyPressed(rl.KeyF7) {
+		showQuestProfile = !showQuestProfile
+	}
+}
+
+// drawQuestProfile shows time spent in commands of each quest template
+func drawQuestProfile() {
+	if !showQuestProfile {
+		return
+	}
+
+	p := &currentGameMode.quests.profile
+	frames := p.frames
+
+	if frames == 0 {
+		frames = 1
+	}
+
+	x := int32(questProfileX)
+	y := int32(questProfileY)
+	rl.DrawText(fmt.Sprintf("Quest profile (%d frames):", p.frames), x, y, 10, rl.Orange)
+	y += 12
+	rl.DrawText(fmt.Sprintf("%-20s %8s %8s %10s %10s", "quest", "runs", "blocked", "total ms", "ms/frame"), x, y, 10, rl.Gray)
+
+	for i, v := range p.summarize(func(k questProfileKey) string { return k.quest }) {
+		y += 12
+
+		if i >= maxQuestProfileRows || y > system.ScreenHeight-12 {
+			break
+		}
+
+		ms := v.time.Seconds() * 1000
+		rl.DrawText(fmt.Sprintf("%-20s %8d %8d %10.3f %10.4f", v.name, v.runs, v.blocks, ms, ms/float64(frames)), x, y, 10, rl.RayWhite)
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+/*
+	Quest progress snapshot
+
+	The save keeps quests in a binary form, started with '-questprogress <file>' the game
+	also writes a readable snapshot of the saved quests to the file each time it's saved.
+	Tools such as the quest graph use it to show how far the player got.
+*/
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var questProgressFile string
+
+var questStateNames = map[int]string{
+	qsInProgress: "in progress",
+	qsFinished:   "finished",
+	qsFailed:     "failed",
+	qsAbandoned:  "abandoned",
+}
+
+type questProgress struct {
+	Quests []questProgressQuest `json:"quests"`
+}
+
+type questProgressQuest struct {
+	ID     int64               `json:"id"`
+	Name   string              `json:"name"`
+	State  string              `json:"state"`
+	Tasks  []questProgressTask `json:"tasks"`
+	Timers map[string]float32  `json:"timers,omitempty"`
+}
+
+type questProgressTask struct {
+	Name    string `json:"name"`
+	Done    bool   `json:"done"`
+	Dormant bool   `json:"dormant,omitempty"`
+	Event   bool   `json:"event,omitempty"`
+	PC      int    `json:"pc"`
+}
+
+// progress takes a snapshot of the saved quests, including the finished ones
+func (s *questManagerSave) progress() questProgress {
+	res := questProgress{
+		Quests: []questProgressQuest{},
+	}
+
+	for _, qs := range s.Quests {
+		v := questProgressQuest{
+			ID:     qs.ID,
+			Name:   qs.Name,
+			State:  questStateNames[qs.State],
+			Tasks:  []questProgressTask{},
+			Timers: map[string]float32{},
+		}
+
+		events := map[string]bool{}
+
+		if qd := parseQuest(qs.Name); qd != nil {
+			for _, t := range qd.taskDef {
+				events[t.name] = t.isEvent
+			}
+		}
+
+		for _, t := range qs.Tasks {
+			v.Tasks = append(v.Tasks, questProgressTask{
+				Name:    t.Name,
+				Done:    t.Done,
+				Dormant: t.Dormant,
+				Event:   events[t.Name],
+				PC:      t.PC,
+			})
+		}
+
+		for k, t := range qs.Timers {
+			v.Timers[k] = t.Time
+		}
+
+		res.Quests = append(res.Quests, v)
+	}
+
+	return res
+}
+
+// saveQuestProgress writes the snapshot of the saved quests to the given file
+func saveQuestProgress(path string, s questManagerSave) {
+	data, err := json.MarshalIndent(s.progress(), "", "  ")
+
+	if err != nil {
+		log.Printf("Quest progress could not be encoded: %s\n", err)
+		return
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		log.Printf("Quest progress could not be saved: %s\n", err)
+		return
+	}
+
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		log.Printf("Quest progress could not be saved: %s\n", err)
+	}
+}
+
+func loadQuestProgress(path string) (questProgress, error) {
+	var res questProgress
+	data, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		return res, err
+	}
+
+	err = json.Unmarshal(data, &res)
+	return res, err
+}
+
+// find returns the most recent run of a quest template
+func (p *questProgress) find(name string) *questProgressQuest {
+	var res *questProgressQuest
+
+	for i, v := range p.Quests {
+		if strings.EqualFold(v.Name, name) && (res == nil || v.ID > res.ID) {
+			res = &p.Quests[i]
+		}
+	}
+
+	return res
+}
@@ -0,0 +1,112 @@
+package main
+
+/*
+	Command reference generator
+
+	The command reference in the questing docs and the list of commands known
+	to the syntax definition are generated from the registered signatures:
+
+	./build/game.exe -gendocs
+*/
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"regexp"
+	"strings"
+)
+
+const (
+	questReferenceDocs   = "docs/questing.md"
+	questReferenceSyntax = "extras/Rurik - Quest.sublime-syntax"
+
+	questReferenceBegin = "<!-- commands:begin -->"
+	questReferenceEnd   = "<!-- commands:end -->"
+
+	questEventsBegin = "<!-- events:begin -->"
+	questEventsEnd   = "<!-- events:end -->"
+)
+
+var questSyntaxCommandsRegex = regexp.MustCompile(`(?m)^  commands: .*$`)
+
+// generateQuestReference updates the docs and the syntax definition with the registered commands
+func generateQuestReference() {
+	q := makeQuestManager()
+
+	updateGeneratedFile(questReferenceDocs, func(text string) string {
+		text = replaceGeneratedSection(questReferenceDocs, text, questReferenceBegin, questReferenceEnd, q.commandReference())
+		return replaceGeneratedSection(questReferenceDocs, text, questEventsBegin, questEventsEnd, invokeEventReference())
+	})
+
+	updateGeneratedFile(questReferenceSyntax, func(text string) string {
+		if !questSyntaxCommandsRegex.MatchString(text) {
+			log.Fatalf("File '%s' is missing the 'commands' variable!\n", questReferenceSyntax)
+		}
+
+		return questSyntaxCommandsRegex.ReplaceAllLiteralString(text, fmt.Sprintf("  commands: '\\b(?:%s)\\b'", strings.Join(q.commandOrder, "|")))
+	})
+}
+
+// commandReference lists commands grouped in the order they were registered
+func (q *questManager) commandReference() string {
+	groups := []string{}
+	byGroup := map[string][]*questCommand{}
+
+	for _, v := range q.commandOrder {
+		cmd := q.commands[v]
+
+		if _, ok := byGroup[cmd.group]; !ok {
+			groups = append(groups, cmd.group)
+		}
+
+		byGroup[cmd.group] = append(byGroup[cmd.group], cmd)
+	}
+
+	var b strings.Builder
+
+	for _, g := range groups {
+		fmt.Fprintf(&b, "\n%s:\n", g)
+
+		for _, cmd := range byGroup[g] {
+			fmt.Fprintf(&b, "- `%s`\n    %s\n", strings.TrimSpace(cmd.name+" "+cmd.usage(true)), cmd.desc)
+		}
+	}
+
+	b.WriteString("\n")
+	return b.String()
+}
+
+// replaceGeneratedSection replaces the text between the markers, path names the file in errors
+func replaceGeneratedSection(path, text, beginMarker, endMarker, content string) string {
+	begin := strings.Index(text, beginMarker)
+	end := strings.Index(text, endMarker)
+
+	if begin == -1 || end < begin {
+		log.Fatalf("File '%s' is missing the '%s' markers!\n", path, beginMarker)
+	}
+
+	return text[:begin+len(beginMarker)] + "\n" + content + text[end:]
+}
+
+func updateGeneratedFile(path string, update func(text string) string) {
+	data, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		log.Fatalf("File '%s' could not be read: %s\n", path, err)
+	}
+
+	// keep the file's line endings
+	crlf := strings.Contains(string(data), "\r\n")
+	text := update(strings.ReplaceAll(string(data), "\r\n", "\n"))
+
+	if crlf {
+		text = strings.ReplaceAll(text, "\n", "\r\n")
+	}
+
+	if err := ioutil.WriteFile(path, []byte(text), 0644); err != nil {
+		log.Fatalf("File '%s' could not be written: %s\n", path, err)
+	}
+
+	log.Printf("File '%s' has been generated!\n", path)
+}
@@ -0,0 +1,235 @@
+package main
+
+/*
+	Quest saves
+
+	The save keeps what changes while quests run: positions of their tasks, variables, timers,
+	stages and states of their random generators. Definitions are parsed from the quest templates
+	again when the game is loaded and tasks are matched by their names, so fixed quest files apply
+	to old saves too.
+*/
+
+import (
+	"log"
+)
+
+// questManagerSave is the state of quests kept in the save
+type questManagerSave struct {
+	Time         float32
+	TrackedQuest int64
+	Seed         int64
+	Quests       []questSave
+}
+
+type questSave struct {
+	ID         int64
+	Name       string
+	State      int
+	FinishedAt float32
+	Rand       uint64
+	Run        string
+	Params     map[string]questVar
+	Timers     map[string]questTimerSave
+	Stages     []questStageSave
+	Tasks      []questTaskSave
+}
+
+type questTimerSave struct {
+	Time     float32
+	Duration float32
+}
+
+type questStageSave struct {
+	ID       int
+	Step     string
+	State    int
+	Order    int
+	Optional bool
+	Hidden   bool
+	Progress int
+	Target   int
+	Waypoint string
+	Timer    string
+}
+
+type questTaskSave struct {
+	Name      string
+	PC        int
+	Done      bool
+	Dormant   bool
+	WaitTime  float32
+	Variables map[string]questVar
+	EventArgs []questVar
+}
+
+// save returns the state of all quests, including the ones added during this frame
+func (q *questManager) save() questManagerSave {
+	res := questManagerSave{
+		Time:         q.time,
+		TrackedQuest: q.trackedQuest,
+		Seed:         q.seed,
+		Quests:       []questSave{},
+	}
+
+	for _, v := range q.quests {
+		res.Quests = append(res.Quests, v.save())
+	}
+
+	for _, v := range q.pendingQuests {
+		res.Quests = append(res.Quests, v.save())
+	}
+
+	return res
+}
+
+func (qs *quest) save() questSave {
+	res := questSave{
+		ID:         qs.ID,
+		Name:       qs.name,
+		State:      qs.state,
+		FinishedAt: qs.finishedAt,
+		Rand:       qs.rng.state,
+		Run:        qs.telemetryRun,
+		Params:     qs.params,
+		Timers:     map[string]questTimerSave{},
+		Stages:     []questStageSave{},
+		Tasks:      []questTaskSave{},
+	}
+
+	for k, v := range qs.timers {
+		res.Timers[k] = questTimerSave{
+			Time:     v.time,
+			Duration: v.duration,
+		}
+	}
+
+	for _, v := range qs.stages {
+		res.Stages = append(res.Stages, questStageSave{
+			ID:       v.id,
+			Step:     v.step,
+			State:    v.state,
+			Order:    v.order,
+			Optional: v.optional,
+			Hidden:   v.hidden,
+			Progress: v.progress,
+			Target:   v.target,
+			Waypoint: v.waypoint,
+			Timer:    v.timer,
+		})
+	}
+
+	for _, v := range qs.tasks {
+		res.Tasks = append(res.Tasks, questTaskSave{
+			Name:      v.name,
+			PC:        v.pc,
+			Done:      v.isDone,
+			Dormant:   v.isDormant,
+			WaitTime:  v.waitTime,
+			Variables: v.variables,
+			EventArgs: v.eventArgs,
+		})
+	}
+
+	return res
+}
+
+// restore replaces running quests with the saved ones
+func (q *questManager) restore(data questManagerSave) {
+	q.reset()
+	q.time = data.Time
+	q.trackedQuest = data.TrackedQuest
+	q.seed = data.Seed
+
+	for _, v := range data.Quests {
+		qs, ok := restoreQuest(v)
+
+		if !ok {
+			continue
+		}
+
+		q.quests = append(q.quests, qs)
+	}
+}
+
+func restoreQuest(data questSave) (quest, bool) {
+	qd := parseQuest(data.Name)
+
+	if qd == nil {
+		log.Printf("Quest '%s' could not be restored, its template is missing!", data.Name)
+		return quest{}, false
+	}
+
+	qs := quest{
+		ID:               data.ID,
+		name:             data.Name,
+		runsInBackground: qd.runsInBackground,
+		questDef:         *qd,
+		params:           data.Params,
+		state:            data.State,
+		finishedAt:       data.FinishedAt,
+		rng:              randSource{state: data.Rand},
+		telemetryRun:     data.Run,
+		timers:           map[string]questTimer{},
+		stages:           map[int]questStage{},
+		tasks:            []questTask{},
+	}
+
+	saved := map[string]questTaskSave{}
+
+	for _, v := range data.Tasks {
+		saved[v.Name] = v
+	}
+
+	for _, v := range qd.taskDef {
+		t := questTask{
+			questTaskDef: v,
+			variables:    map[string]questVar{},
+		}
+
+		if st, ok := saved[v.name]; ok {
+			t.pc = st.PC
+			t.isDone = st.Done
+			t.isDormant = st.Dormant
+			t.waitTime = st.WaitTime
+			t.eventArgs = st.EventArgs
+
+			if st.Variables != nil {
+				t.variables = st.Variables
+			}
+		} else {
+			log.Printf("Quest '%s' has a new task '%s' since it was saved, starting it from the beginning", data.Name, v.name)
+		}
+
+		qs.tasks = append(qs.tasks, t)
+	}
+
+	if len(qs.tasks) == 0 {
+		log.Printf("Quest '%s' could not be restored, it has no tasks!", data.Name)
+		return quest{}, false
+	}
+
+	for k, v := range data.Timers {
+		qs.timers[k] = questTimer{
+			time:     v.Time,
+			duration: v.Duration,
+		}
+	}
+
+	for _, v := range data.Stages {
+		qs.stages[v.ID] = questStage{
+			id:       v.ID,
+			step:     v.Step,
+			state:    v.State,
+			order:    v.Order,
+			optional: v.Optional,
+			hidden:   v.Hidden,
+			progress: v.Progress,
+			target:   v.Target,
+			waypoint: v.Waypoint,
+			timer:    v.Timer,
+		}
+	}
+
+	qs.activeQuestTask = &qs.tasks[0]
+	return qs, true
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"encoding/gob"
+	"testing"
+
+	"github.com/zaklaus/rurik/src/core"
+)
+
+const saveTestQuest = `TITLE: Save test
+QRC:
+
+STAGE: 100 (target=3)
+Collect the mushrooms.
+
+QST:
+
+setvar found 2
+setvec spot 12 34
+setstr keeper Bob
+setvar world.visitedForest 1
+setstr world.smithName Alice
+timer hurry 30
+stage 100
+fire hurry
+wait 1000
+setvar found 3
+`
+
+// withQuestFiles serves quest templates from memory and sets up an empty game mode
+func withQuestFiles(t *testing.T, files map[string]string) {
+	read := readAsset
+
+	readAsset = func(name string) []byte {
+		if v, ok := files[name]; ok {
+			return []byte(v)
+		}
+
+		return nil
+	}
+
+	// quests read the player's position and health every step
+	core.LocalPlayer = &core.Object{}
+	barStats = make([]barStat, barUltimate+1)
+
+	currentGameMode = &gameMode{
+		quests: makeQuestManager(),
+		world:  makeWorldState(),
+	}
+
+	t.Cleanup(func() {
+		readAsset = read
+	})
+}
+
+// saveAndLoad round-trips the game mode through the save
+func saveAndLoad(t *testing.T, g *gameMode) *gameMode {
+	var buf bytes.Buffer
+	g.Serialize(gob.NewEncoder(&buf))
+
+	res := &gameMode{
+		quests: makeQuestManager(),
+		world:  makeWorldState(),
+	}
+
+	currentGameMode = res
+	res.Deserialize(gob.NewDecoder(&buf))
+
+	return res
+}
+
+func TestQuestSaveRoundTrip(t *testing.T) {
+	withQuestFiles(t, map[string]string{
+		"quests/savetest.qst": saveTestQuest,
+	})
+
+	g := currentGameMode
+	ok, reason, id := g.quests.addQuest("savetest", nil)
+
+	if !ok {
+		t.Fatalf("quest could not be added: %s", reason)
+	}
+
+	g.quests.time = 42
+	loaded := saveAndLoad(t, g)
+
+	if len(loaded.quests.quests) != 1 {
+		t.Fatalf("got %d quests after loading, want 1", len(loaded.quests.quests))
+	}
+
+	qs := loaded.quests.findQuest(id)
+
+	if qs == nil {
+		t.Fatalf("quest %d is missing after loading", id)
+	}
+
+	if loaded.quests.time != 42 || loaded.quests.trackedQuest != id {
+		t.Errorf("got time %v and tracked quest %d, want 42 and %d", loaded.quests.time, loaded.quests.trackedQuest, id)
+	}
+
+	if val, ok := qs.getVariable("found"); !ok || val != 2 {
+		t.Errorf("got found = %v, want 2", val)
+	}
+
+	if val, ok := qs.getVector("spot"); !ok || val.X != 12 || val.Y != 34 {
+		t.Errorf("got spot = %v, want [12, 34]", val)
+	}
+
+	if val, ok := qs.getAnyVariable("keeper"); !ok || val.kind != kindString || val.value.str() != "Bob" {
+		t.Errorf("got keeper = %+v, want 'Bob'", val)
+	}
+
+	if val, ok := loaded.world.getNumber("world.visitedForest"); !ok || val != 1 {
+		t.Errorf("got world.visitedForest = %v, want 1", val)
+	}
+
+	if val, ok := loaded.world.get("world.smithName"); !ok || val.kind != kindString || val.value.str() != "Alice" {
+		t.Errorf("got world.smithName = %+v, want 'Alice'", val)
+	}
+
+	if tm, ok := qs.timers["hurry"]; !ok || tm.duration != 30 || tm.time != 30 {
+		t.Errorf("got timer %+v, want a running 30 second timer", tm)
+	}
+
+	if st, ok := qs.stages[100]; !ok || st.target != 3 || st.state != qsInProgress {
+		t.Errorf("got stage %+v, want stage 100 in progress with target 3", st)
+	}
+
+	if qs.tasks[0].pc != g.quests.quests[0].tasks[0].pc {
+		t.Errorf("got pc %d, want %d", qs.tasks[0].pc, g.quests.quests[0].tasks[0].pc)
+	}
+}
+
+// rollDice runs randint a number of times and returns the rolls
+func rollDice(q *questManager, qs *quest, n int) []float64 {
+	res := []float64{}
+
+	for i := 0; i < n; i++ {
+		q.runCommand(qs, &qs.tasks[0], "randint", []string{"roll", "1", "100"})
+		val, _ := qs.getVariable("roll")
+		res = append(res, val)
+	}
+
+	return res
+}
+
+func TestQuestSaveKeepsRandomSequence(t *testing.T) {
+	withQuestFiles(t, map[string]string{
+		"quests/dice.qst": "TITLE: Dice\n\nQST:\n\nwait 1000\n",
+	})
+
+	g := currentGameMode
+	g.quests.seed = 1234
+	_, reason, id := g.quests.addQuest("dice", nil)
+	qs := g.quests.findQuest(id)
+
+	if qs == nil {
+		t.Fatalf("quest could not be added: %s", reason)
+	}
+
+	rollDice(&g.quests, qs, 3)
+	loaded := saveAndLoad(t, g)
+
+	if loaded.quests.seed != 1234 {
+		t.Errorf("got seed %d after loading, want 1234", loaded.quests.seed)
+	}
+
+	want := rollDice(&g.quests, qs, 5)
+	got := rollDice(&loaded.quests, loaded.quests.findQuest(id), 5)
+
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got rolls %v after loading, want %v", got, want)
+		}
+	}
+}
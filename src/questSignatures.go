@@ -0,0 +1,240 @@
+package main
+
+/*
+	Command signatures
+
+	Commands declare their arguments when they're registered, e.g. '<dest:vector> <lhs:vector> <rhs:number>'.
+	Arguments in square brackets are optional and '...' after the kind makes the last one variadic.
+	The dispatcher checks the number of arguments and makes sure numbers evaluate and vectors, resources
+	and timers exist before the handler runs, so handlers don't have to. Names declared by the command
+	itself are exempt. The kinds are also used by the language server to look up references and by
+	the generated command reference.
+*/
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+const (
+	argWord = iota
+	argNumber
+	argVariable
+	argVector
+	argResource
+	argTimer
+	argTask
+	argLabel
+	argText
+)
+
+var questArgKinds = map[string]int{
+	"word":     argWord,
+	"number":   argNumber,
+	"variable": argVariable,
+	"vector":   argVector,
+	"resource": argResource,
+	"timer":    argTimer,
+	"task":     argTask,
+	"label":    argLabel,
+	"text":     argText,
+}
+
+// questArgRefs lists declarations an argument of the given kind refers to
+var questArgRefs = map[int][]int{
+	argResource: {declResource},
+	argTimer:    {declTimer},
+	argTask:     {declTask},
+	argLabel:    {declLabel, declTask},
+}
+
+type questArg struct {
+	name     string
+	kind     int
+	optional bool
+	variadic bool
+}
+
+// questCommand is a registered command along with its signature
+type questCommand struct {
+	name    string
+	group   string
+	args    []questArg
+	desc    string
+	handler questCommandTable
+}
+
+// parseQuestSignature reads arguments in form of '<name:kind> [name:kind...]'
+func parseQuestSignature(cmd, sig string) []questArg {
+	res := []questArg{}
+
+	for _, v := range strings.Fields(sig) {
+		arg := questArg{}
+
+		switch {
+		case strings.HasPrefix(v, "<") && strings.HasSuffix(v, ">"):
+		case strings.HasPrefix(v, "[") && strings.HasSuffix(v, "]"):
+			arg.optional = true
+		default:
+			log.Fatalf("Command '%s' has a malformed argument '%s'!\n", cmd, v)
+		}
+
+		v = v[1 : len(v)-1]
+
+		if strings.HasSuffix(v, "...") {
+			arg.variadic = true
+			v = strings.TrimSuffix(v, "...")
+		}
+
+		kv := strings.SplitN(v, ":", 2)
+		arg.name = kv[0]
+
+		if len(kv) == 2 {
+			kind, ok := questArgKinds[kv[1]]
+
+			if !ok {
+				log.Fatalf("Command '%s' has an argument '%s' of unknown kind '%s'!\n", cmd, arg.name, kv[1])
+			}
+
+			arg.kind = kind
+		}
+
+		if n := len(res); n > 0 && (res[n-1].variadic || (res[n-1].optional && !arg.optional)) {
+			log.Fatalf("Command '%s' has argument '%s' following an optional or variadic one!\n", cmd, arg.name)
+		}
+
+		res = append(res, arg)
+	}
+
+	return res
+}
+
+// arity returns the number of arguments the command accepts, max is -1 when it's variadic
+func (c *questCommand) arity() (min, max int) {
+	for _, v := range c.args {
+		if v.variadic {
+			if !v.optional {
+				min++
+			}
+
+			return min, -1
+		}
+
+		if !v.optional {
+			min++
+		}
+
+		max++
+	}
+
+	return
+}
+
+// acceptsArgs checks whether the command can be called with the given number of arguments
+func (c *questCommand) acceptsArgs(n int) bool {
+	min, max := c.arity()
+	return n >= min && (max == -1 || n <= max)
+}
+
+// expectedArgs describes the number of arguments the command accepts
+func (c *questCommand) expectedArgs() string {
+	min, max := c.arity()
+
+	switch {
+	case max == -1:
+		return fmt.Sprintf("at least '%d'", min)
+	case min == max:
+		return fmt.Sprintf("'%d'", min)
+	default:
+		return fmt.Sprintf("'%d' to '%d'", min, max)
+	}
+}
+
+// usage formats the command's arguments, optionally along with their kinds
+func (c *questCommand) usage(kinds bool) string {
+	res := []string{}
+
+	for _, v := range c.args {
+		arg := v.name
+
+		if kinds && v.kind != argWord {
+			arg += ":" + questArgKindName(v.kind)
+		}
+
+		if v.variadic {
+			arg += "..."
+		}
+
+		if v.optional {
+			arg = "[" + arg + "]"
+		} else {
+			arg = "<" + arg + ">"
+		}
+
+		res = append(res, arg)
+	}
+
+	return strings.Join(res, " ")
+}
+
+// argAt returns the signature of the n-th argument
+func (c *questCommand) argAt(n int) *questArg {
+	if n < len(c.args) {
+		return &c.args[n]
+	}
+
+	if l := len(c.args); l > 0 && c.args[l-1].variadic {
+		return &c.args[l-1]
+	}
+
+	return nil
+}
+
+// checkArgs makes sure the arguments can be resolved to the kinds the command expects
+func (c *questCommand) checkArgs(qs *quest, qt *questTask, args []string) questCommandResult {
+	_, declares := questDeclCommands[c.name]
+
+	for i, v := range args {
+		if i == 0 && declares {
+			continue
+		}
+
+		arg := c.argAt(i)
+
+		if arg == nil {
+			continue
+		}
+
+		switch arg.kind {
+		case argNumber:
+			if _, ok := qs.getNumberOrVariable(v); !ok {
+				return questCommandErrorArgType(c.name, qs, qt, v, "string", "number")
+			}
+		case argVector:
+			if _, ok := qs.getVector(v); !ok {
+				return questCommandErrorThing(c.name, "vector", qs, qt, v)
+			}
+		case argResource:
+			if _, ok := qs.getResource(v); !ok {
+				return questCommandErrorThing(c.name, "resource", qs, qt, v)
+			}
+		case argTimer:
+			if _, ok := qs.timers[v]; !ok {
+				return questCommandErrorThing(c.name, "timer", qs, qt, v)
+			}
+		}
+	}
+
+	return qcContinue
+}
+
+func questArgKindName(kind int) string {
+	for k, v := range questArgKinds {
+		if v == kind {
+			return k
+		}
+	}
+
+	return ""
+}
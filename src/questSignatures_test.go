@@ -0,0 +1,55 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestQuestCommandArgKinds(t *testing.T) {
+	withQuestFiles(t, map[string]string{
+		"quests/kinds.qst": "TITLE: Kinds\nQRC:\n\nMESSAGE: hello\nHi!\n\nQST:\n\nwait 1000\n",
+	})
+
+	q := currentGameMode.quests
+	_, reason, id := q.addQuest("kinds", nil)
+	qs := q.findQuest(id)
+
+	if qs == nil {
+		t.Fatalf("quest could not be added: %s", reason)
+	}
+
+	qt := &qs.tasks[0]
+	qs.setVariable("count", 2)
+	qs.timers["hurry"] = questTimer{time: -1, duration: 5}
+
+	tests := []struct {
+		cmd  string
+		args []string
+		code int
+	}{
+		{"setvec", []string{"spot", "count", "4"}, 0},
+		{"setvec", []string{"spot", "missing", "4"}, qeArgType},
+		{"addvec", []string{"sum", "spot", "spot"}, 0},
+		{"addvec", []string{"sum", "spot", "other"}, qeNotFound},
+		{"copyvec", []string{"copy", "other"}, qeNotFound},
+		{"say", []string{"hello"}, 0},
+		{"say", []string{"goodbye"}, qeNotFound},
+		{"timer", []string{"later", "count * 2"}, 0},
+		{"fire", []string{"hurry"}, 0},
+		{"stop", []string{"never"}, qeNotFound},
+		{"randint", []string{"roll", "1", "count"}, 0},
+		{"when", []string{"count", "above", "nothing"}, qeArgType},
+	}
+
+	for _, tc := range tests {
+		qs.lastError = questError{}
+		res := q.runCommand(qs, qt, tc.cmd, tc.args)
+
+		if tc.code == 0 && res == qcError {
+			t.Errorf("%s %v failed: %s", tc.cmd, tc.args, qs.lastError.message)
+		}
+
+		if tc.code != 0 && (res != qcError || qs.lastError.code != tc.code) {
+			t.Errorf("%s %v gave error code %d, want %d", tc.cmd, tc.args, qs.lastError.code, tc.code)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	rl "github.com/zaklaus/raylib-go/raylib"
+	"github.com/zaklaus/rurik/src/core"
+)
+
+var (
+	stageNotificationColors = map[int]rl.Color{
+		qsInProgress: rl.RayWhite,
+		qsFinished:   rl.Lime,
+		qsFailed:     rl.Red,
+	}
+)
+
+func (s questStage) String() string {
+	return s.describe(localizeText(s.step))
+}
+
+// text returns the step with the quest's variables filled in, along with the progress of the stage
+func (s questStage) text(qs *quest) string {
+	return s.describe(qs.processText(s.step))
+}
+
+// describe adds the progress counter and the optional marker to the step's text
+func (s questStage) describe(text string) string {
+	if s.target > 0 {
+		text = fmt.Sprintf("%s (%d/%d)", text, s.progress, s.target)
+	}
+
+	if s.optional {
+		text = fmt.Sprintf("%s %s", text, tr("ui.objective.optional"))
+	}
+
+	return text
+}
+
+// marker returns a check box describing the stage's state
+func (s questStage) marker() string {
+	switch s.state {
+	case qsFinished:
+		return "[x]"
+	case qsFailed:
+		return "[-]"
+	}
+
+	return "[ ]"
+}
+
+// isVisible reports whether the stage can be shown to the player,
+// hidden stages show up once they're revealed or resolved
+func (s questStage) isVisible() bool {
+	return !s.hidden || s.state != qsInProgress
+}
+
+func (qs *quest) addStage(id int, res *questResource, target int) questStage {
+	if target == 0 {
+		target = res.target
+	}
+
+	sta := questStage{
+		id:       id,
+		step:     res.content,
+		state:    qsInProgress,
+		order:    res.order,
+		optional: res.optional,
+		hidden:   res.hidden,
+		target:   target,
+		waypoint: res.waypoint,
+		timer:    res.timer,
+	}
+
+	qs.stages[id] = sta
+	qs.logStageTelemetry(id, qsInProgress)
+
+	if sta.isVisible() {
+		qs.notifyStage(sta, tr("ui.objective.new"))
+	}
+
+	return sta
+}
+
+func (qs *quest) setStageState(id, state int) bool {
+	sta, ok := qs.stages[id]
+
+	if !ok {
+		return false
+	}
+
+	if sta.state == state {
+		return true
+	}
+
+	sta.state = state
+	qs.stages[id] = sta
+	qs.logStageTelemetry(id, state)
+
+	switch state {
+	case qsFinished:
+		qs.notifyStage(sta, tr("ui.objective.completed"))
+	case qsFailed:
+		qs.notifyStage(sta, tr("ui.objective.failed"))
+	}
+
+	return true
+}
+
+// progressStage advances the stage's counter, the stage is completed once it reaches its target
+func (qs *quest) progressStage(id, amount int) bool {
+	sta, ok := qs.stages[id]
+
+	if !ok {
+		return false
+	}
+
+	if sta.state != qsInProgress {
+		return true
+	}
+
+	sta.progress += amount
+
+	if sta.progress < 0 {
+		sta.progress = 0
+	}
+
+	qs.stages[id] = sta
+
+	if sta.target > 0 && sta.progress >= sta.target {
+		sta.progress = sta.target
+		qs.stages[id] = sta
+
+		return qs.setStageState(id, qsFinished)
+	}
+
+	if sta.isVisible() {
+		qs.notifyStage(sta, tr("ui.objective.updated"))
+	}
+
+	return true
+}
+
+func (qs *quest) revealStage(id int) bool {
+	sta, ok := qs.stages[id]
+
+	if !ok {
+		return false
+	}
+
+	if sta.hidden {
+		sta.hidden = false
+		qs.stages[id] = sta
+		qs.notifyStage(sta, tr("ui.objective.new"))
+	}
+
+	return true
+}
+
+// getObjectives returns visible stages in order, their texts are resolved when shown
+// so they can refer to variables which change over time
+func (qs *quest) getObjectives() []questStage {
+	res := []questStage{}
+
+	for _, v := range qs.stages {
+		if v.isVisible() {
+			res = append(res, v)
+		}
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].order == res[j].order {
+			return res[i].id < res[j].id
+		}
+
+		return res[i].order < res[j].order
+	})
+
+	return res
+}
+
+// getCurrentStage returns the first objective in progress
+func (qs *quest) getCurrentStage() (questStage, bool) {
+	for _, v := range qs.getObjectives() {
+		if v.state == qsInProgress {
+			return v, true
+		}
+	}
+
+	return questStage{}, false
+}
+
+// getWaypoint resolves the stage's waypoint, either a quest vector or a map object
+func (qs *quest) getWaypoint(sta questStage) (rl.Vector2, bool) {
+	if sta.waypoint == "" {
+		return rl.Vector2{}, false
+	}
+
+	if vec, ok := qs.getVector(sta.waypoint); ok {
+		return vec, true
+	}
+
+	if core.CurrentMap == nil || core.CurrentMap.World == nil {
+		return rl.Vector2{}, false
+	}
+
+	obj, ok := core.CurrentMap.World.FindObject(sta.waypoint)
+
+	if !ok {
+		return rl.Vector2{}, false
+	}
+
+	return obj.Position, true
+}
+
+func (qs *quest) notifyStage(sta questStage, reason string) {
+	if qs.runsInBackground {
+		return
+	}
+
+	PushNotification(fmt.Sprintf("%s: %s", reason, sta.text(qs)), stageNotificationColors[sta.state])
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+const (
+	// named resources get IDs starting from this one, in order of declaration
+	questNamedResourceBase = 1 << 20
+)
+
+// resourceID turns a resource's ID or name into its numeric ID
+func (p *questParser) resourceID(word string, pos int) int {
+	if id, err := strconv.Atoi(word); err == nil {
+		return id
+	}
+
+	if id, ok := p.resourceNames[word]; ok {
+		p.reportf(pos, "resource '%s' is already defined", word)
+		return id
+	}
+
+	if _, ok := p.symbols[word]; ok {
+		p.reportf(pos, "resource '%s' clashes with a constant of the same name", word)
+	}
+
+	id := questNamedResourceBase + len(p.resourceNames)
+	p.resourceNames[word] = id
+	p.symbols[word] = strconv.Itoa(id)
+
+	return id
+}
+
+// parseConstants reads numeric constants in form of: <name> <value>
+func (p *questParser) parseConstants() {
+	p.skipSeparators()
+
+	for t := p.peekToken(); t.kind == tkIdentifier && p.peekKeyword() == ""; t = p.peekToken() {
+		name := p.nextIdentifier()
+		value := p.nextWord()
+
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			p.reportf(t.wordPos, "constant '%s' has to be a number, got: '%s'", name, value)
+		} else if _, ok := p.symbols[name]; ok {
+			p.reportf(t.wordPos, "constant '%s' is already defined", name)
+		} else {
+			p.symbols[name] = value
+			p.declare(declConstant, name, t.wordPos, value)
+		}
+
+		p.skipSeparators()
+	}
+}
+
+// peekKeyword returns the upcoming section name or flag, if there's any
+func (p *questParser) peekKeyword() string {
+	op := *p
+	defer func() { *p = op }()
+
+	p.skipSeparators()
+	tk := p.parseToken()
+
+	if tk.kind != tkIdentifier {
+		return ""
+	}
+
+	if strings.HasPrefix(tk.text, "+") || strings.ToLower(tk.text) == kwMacro {
+		return tk.text
+	}
+
+	if next := p.parseToken(); next.kind == tkIdentifier && next.text == kwScope {
+		return tk.text
+	}
+
+	return ""
+}
+
+// resolveSymbols replaces constants and resource names in the command's arguments
+func (p *questParser) resolveSymbols(qc questCmd) questCmd {
+	if len(p.symbols) == 0 {
+		return qc
+	}
+
+	args := make([]string, len(qc.args))
+
+	for i, v := range qc.args {
+		args[i] = p.resolveWord(v)
+	}
+
+	qc.args = args
+	qc.timeout = p.resolveWord(qc.timeout)
+
+	return qc
+}
+
+func (p *questParser) resolveWord(word string) string {
+	if val, ok := p.symbols[word]; ok {
+		return val
+	}
+
+	// expressions like '(maxWolves - 1)'
+	if !strings.HasPrefix(word, kwLeftBrace) {
+		return word
+	}
+
+	for k, v := range p.symbols {
+		word = replaceWord(word, k, v)
+	}
+
+	return word
+}
+
+// resourceID turns a resource's ID or name into its numeric ID
+func (qs *quest) resourceID(arg string) (int, bool) {
+	if id, ok := qs.resourceNames[arg]; ok {
+		return id, true
+	}
+
+	id, err := strconv.Atoi(arg)
+	return id, err == nil
+}
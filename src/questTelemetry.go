@@ -0,0 +1,120 @@
+package main
+
+/*
+	Quest telemetry
+
+	Started with '-telemetry <file>', the game appends the quest flow of the session
+	to a JSONL file: quests added, finished and failed, stages changed, tasks done,
+	events received and dialogue choices picked, along with the map and the player's position.
+	Logs of several playtests are combined by the 'questtelemetry' tool.
+*/
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/zaklaus/rurik-prototype/src/telemetry"
+	"github.com/zaklaus/rurik/src/core"
+)
+
+var (
+	questTelemetryFile string
+	questTelemetry     *telemetry.Log
+)
+
+func openQuestTelemetry() {
+	if questTelemetryFile == "" {
+		return
+	}
+
+	var err error
+	questTelemetry, err = telemetry.Open(questTelemetryFile)
+
+	if err != nil {
+		log.Printf("Quest telemetry could not be opened: %s\n", err)
+	}
+}
+
+func closeQuestTelemetry() {
+	if questTelemetry == nil {
+		return
+	}
+
+	questTelemetry.Close()
+	questTelemetry = nil
+}
+
+// logTelemetry records an event along with the game time, map and the player's position
+func logTelemetry(e telemetry.Event) {
+	if questTelemetry == nil {
+		return
+	}
+
+	e.GameTime = currentGameMode.quests.time
+	e.Map = currentGameMode.mapName
+
+	if core.LocalPlayer != nil {
+		e.X = core.LocalPlayer.Position.X
+		e.Y = core.LocalPlayer.Position.Y
+	}
+
+	if err := questTelemetry.Write(e); err != nil {
+		log.Printf("Quest telemetry could not be written: %s\n", err)
+	}
+}
+
+// logTelemetry records an event of the quest
+func (qs *quest) logTelemetry(kind string, e telemetry.Event) {
+	e.Kind = kind
+	e.Quest = qs.name
+	e.QuestID = qs.ID
+
+	if qs.telemetryRun == "" && questTelemetry != nil {
+		qs.telemetryRun = fmt.Sprintf("%s/%d", questTelemetry.Session(), qs.ID)
+	}
+
+	e.Run = qs.telemetryRun
+	logTelemetry(e)
+}
+
+func (qs *quest) logStageTelemetry(id, state int) {
+	qs.logTelemetry(telemetry.KindStageChanged, telemetry.Event{
+		Stage: id,
+		State: questStateNames[state],
+	})
+}
+
+func (qs *quest) logTaskTelemetry(qt *questTask) {
+	qs.logTelemetry(telemetry.KindTaskDone, telemetry.Event{Task: qt.name})
+}
+
+func (qs *quest) logEventTelemetry(name string) {
+	qs.logTelemetry(telemetry.KindEventReceived, telemetry.Event{Event: name})
+}
+
+// setState ends the quest with the given state
+func (qs *quest) setState(state int) {
+	if qs.state == state {
+		return
+	}
+
+	qs.state = state
+
+	switch state {
+	case qsFinished:
+		qs.logTelemetry(telemetry.KindQuestFinished, telemetry.Event{})
+	case qsFailed:
+		qs.logTelemetry(telemetry.KindQuestFailed, telemetry.Event{})
+	case qsAbandoned:
+		qs.logTelemetry(telemetry.KindQuestAbandoned, telemetry.Event{})
+	}
+}
+
+func logDialogueChoice(name string, node *Dialogue, choice *Choice) {
+	logTelemetry(telemetry.Event{
+		Kind:     telemetry.KindDialogueChoice,
+		Dialogue: name,
+		Node:     node.ID,
+		Choice:   choice.Text,
+	})
+}
@@ -1,16 +1,59 @@
 package main
 
 import (
+	"encoding/gob"
 	"fmt"
 	"math"
 
 	rl "github.com/zaklaus/raylib-go/raylib"
 )
 
+func init() {
+	// variables hold their values as questVarData, gob needs to know the concrete types
+	gob.Register(&questVarNumber{})
+	gob.Register(&questVarVector{})
+	gob.Register(&questVarString{})
+}
+
+// questVarSave is the form variables are saved in
+type questVarSave struct {
+	Kind  int
+	Value questVarData
+}
+
+// GobEncode saves the variable along with its value
+func (v questVar) GobEncode() ([]byte, error) {
+	return gobEncode(questVarSave{
+		Kind:  v.kind,
+		Value: v.value,
+	})
+}
+
+// GobDecode restores the variable from the save
+func (v *questVar) GobDecode(data []byte) error {
+	var res questVarSave
+
+	if err := gobDecode(data, &res); err != nil {
+		return err
+	}
+
+	v.kind = res.Kind
+	v.value = res.Value
+	return nil
+}
+
 type questVarNumber struct {
 	value float64
 }
 
+func (v *questVarNumber) GobEncode() ([]byte, error) {
+	return gobEncode(v.value)
+}
+
+func (v *questVarNumber) GobDecode(data []byte) error {
+	return gobDecode(data, &v.value)
+}
+
 func (v *questVarNumber) str() string {
 	if math.Floor(v.value) == v.value {
 		return fmt.Sprintf("%d", int64(v.value))
@@ -23,6 +66,30 @@ type questVarVector struct {
 	value rl.Vector2
 }
 
+func (v *questVarVector) GobEncode() ([]byte, error) {
+	return gobEncode(v.value)
+}
+
+func (v *questVarVector) GobDecode(data []byte) error {
+	return gobDecode(data, &v.value)
+}
+
 func (v *questVarVector) str() string {
-	return fmt.Sprintf("[%f, %f]", v.value)
+	return fmt.Sprintf("[%f, %f]", v.value.X, v.value.Y)
+}
+
+type questVarString struct {
+	value string
+}
+
+func (v *questVarString) GobEncode() ([]byte, error) {
+	return gobEncode(v.value)
+}
+
+func (v *questVarString) GobDecode(data []byte) error {
+	return gobDecode(data, &v.value)
+}
+
+func (v *questVarString) str() string {
+	return v.value
 }
@@ -3,7 +3,7 @@ package main
 import (
 	"fmt"
 	"log"
-	"math/rand"
+	"path"
 	"strconv"
 	"strings"
 
@@ -18,6 +18,7 @@ const (
 	qsInProgress = iota
 	qsFinished
 	qsFailed
+	qsAbandoned
 )
 
 type quest struct {
@@ -30,11 +31,26 @@ type quest struct {
 	tasks            []questTask
 	activeQuestTask  *questTask
 	questDef
+
+	lastError questError
+
+	// parameters the quest was started with
+	params map[string]questVar
+
+	// game time the quest was finished at
+	finishedAt float32
+
+	// state of the quest's own random generator
+	rng randSource
+
+	// identifies the quest's run in telemetry, it's kept in the save so runs span sessions
+	telemetryRun string
 }
 
 const (
 	kindNumber = iota
 	kindVector
+	kindString
 )
 
 type questVarData interface {
@@ -49,6 +65,11 @@ type questVar struct {
 type questTask struct {
 	variables map[string]questVar
 	questTaskDef
+
+	// per-frame command budget, see questCommandBudget
+	budget          int
+	budgetExhausted bool
+	runawayFrames   int
 }
 
 type questTimer struct {
@@ -57,8 +78,21 @@ type questTimer struct {
 }
 
 type questStage struct {
+	id    int
 	step  string
 	state int
+
+	order    int
+	optional bool
+	hidden   bool
+	progress int
+	target   int
+
+	// map object or quest vector the stage points to
+	waypoint string
+
+	// timer shown as a countdown
+	timer string
 }
 
 func (qs *quest) printf(qt *questTask, format string, args ...interface{}) {
@@ -66,9 +100,9 @@ func (qs *quest) printf(qt *questTask, format string, args ...interface{}) {
 }
 
 func (qs *quest) getResource(id string) (*questResource, bool) {
-	val, err := strconv.Atoi(id)
+	val, ok := qs.resourceID(id)
 
-	if err != nil {
+	if !ok {
 		return nil, false
 	}
 
@@ -92,7 +126,8 @@ func (qs *quest) getNumberOrVariable(arg string) (float64, bool) {
 			return 0, false
 		}
 
-		res, err := expr.Evaluate(nil)
+		// unknown names have to fail the evaluation instead of crashing it
+		res, err := expr.Evaluate(map[string]interface{}{})
 
 		if err != nil {
 			return 0, false
@@ -123,14 +158,18 @@ func (qs *quest) getRelevantVariables() (a map[string]questVar) {
 }
 
 func (qs *quest) processText(content string) string {
+	content = localizeText(content)
+
 	for k, v := range qs.getRelevantVariables() {
 		content = strings.ReplaceAll(content, fmt.Sprintf("%%%s%%", k), v.value.str())
 	}
 
-	return content
+	return currentGameMode.world.processText(content)
 }
 
 func (qs *quest) resolveVariables(expr string) string {
+	expr = currentGameMode.world.resolveFlags(expr)
+
 	for k, v := range qs.getRelevantVariables() {
 		expr = strings.ReplaceAll(expr, k, v.value.str())
 	}
@@ -151,6 +190,11 @@ func (qs *quest) getTaskOverride(name string) *questTask {
 }
 
 func (qs *quest) setVariable(name string, val float64) {
+	if isWorldFlag(name) {
+		currentGameMode.world.setNumber(name, val)
+		return
+	}
+
 	qs.getTaskOverride(name).variables[name] = questVar{
 		kind:  kindNumber,
 		value: &questVarNumber{value: val},
@@ -158,30 +202,110 @@ func (qs *quest) setVariable(name string, val float64) {
 }
 
 func (qs *quest) setVector(name string, val rl.Vector2) {
+	if isWorldFlag(name) {
+		currentGameMode.world.setVector(name, val)
+		return
+	}
+
 	qs.getTaskOverride(name).variables[name] = questVar{
 		kind:  kindVector,
 		value: &questVarVector{value: val},
 	}
 }
 
+func (qs *quest) setString(name string, val string) {
+	if isWorldFlag(name) {
+		currentGameMode.world.setString(name, val)
+		return
+	}
+
+	qs.getTaskOverride(name).variables[name] = questVar{
+		kind:  kindString,
+		value: &questVarString{value: val},
+	}
+}
+
 func (qs *quest) getVariable(name string) (float64, bool) {
+	if isWorldFlag(name) {
+		return currentGameMode.world.getNumber(name)
+	}
+
 	vars := qs.getRelevantVariables()
 
 	val, ok := vars[name]
 
-	if !ok {
+	if !ok || val.kind != kindNumber {
+		return 0, false
+	}
+
+	return val.value.(*questVarNumber).value, true
+}
+
+// setAnyVariable sets a variable of any kind
+func (qs *quest) setAnyVariable(name string, val questVar) {
+	switch val.kind {
+	case kindNumber:
+		qs.setVariable(name, val.value.(*questVarNumber).value)
+	case kindVector:
+		qs.setVector(name, val.value.(*questVarVector).value)
+	case kindString:
+		qs.setString(name, val.value.(*questVarString).value)
+	}
+}
+
+// getArgValue turns a command argument into a value, it's either a copy of a variable,
+// a number or a plain string
+func (qs *quest) getArgValue(arg string) questVar {
+	if val, ok := qs.getAnyVariable(arg); ok {
+		return val
+	}
+
+	if num, ok := qs.getNumberOrVariable(arg); ok {
+		return makeQuestVarNumber(num)
+	}
+
+	return makeQuestVarString(arg)
+}
+
+// getAnyVariable looks up a variable regardless of its kind
+func (qs *quest) getAnyVariable(name string) (questVar, bool) {
+	if isWorldFlag(name) {
+		return currentGameMode.world.get(name)
+	}
+
+	val, ok := qs.getRelevantVariables()[name]
+	return val, ok
+}
+
+// getGlobalVariable reads a number declared in the entry point
+func (qs *quest) getGlobalVariable(name string) (float64, bool) {
+	val, ok := qs.tasks[0].variables[name]
+
+	if !ok || val.kind != kindNumber {
 		return 0, false
 	}
 
 	return val.value.(*questVarNumber).value, true
 }
 
+// setGlobalVariable sets a number in the entry point, making it visible to all tasks
+func (qs *quest) setGlobalVariable(name string, val float64) {
+	qs.tasks[0].variables[name] = questVar{
+		kind:  kindNumber,
+		value: &questVarNumber{value: val},
+	}
+}
+
 func (qs *quest) getVector(name string) (rl.Vector2, bool) {
+	if isWorldFlag(name) {
+		return currentGameMode.world.getVector(name)
+	}
+
 	vars := qs.getRelevantVariables()
 
 	val, ok := vars[name]
 
-	if !ok {
+	if !ok || val.kind != kindVector {
 		return rl.Vector2{}, false
 	}
 
@@ -210,31 +334,163 @@ func (qs *quest) processTask(q *questManager, qt *questTask) bool {
 		return false
 	}
 
+	if qt.isDormant {
+		return false
+	}
+
+	if qt.budget <= 0 {
+		qt.budgetExhausted = true
+		return false
+	}
+
+	qt.budget--
 	qs.activeQuestTask = qt
 
 	qs.processVariables()
 
 	cmd := qt.commands[qt.pc]
-	ok, err := q.dispatchCommand(qs, qt, cmd.name, cmd.args)
 
-	if err {
-		qt.isDone = true
+	switch q.dispatchCommand(qs, qt, cmd.name, cmd.args) {
+	case qcBlock:
+		return qs.checkTimeout(q, qt, cmd)
+	case qcError:
+		return qs.handleError(q, qt)
+	}
+
+	qt.pc++
+	qt.waitTime = 0
+	return true
+}
+
+// checkTimeout counts the time a command has been blocking the task for,
+// it jumps to the command's target once the timeout has been reached
+func (qs *quest) checkTimeout(q *questManager, qt *questTask, cmd questCmd) bool {
+	qt.waitTime += system.FrameTime
+
+	if cmd.timeout == "" {
 		return false
 	}
 
+	limit, ok := qs.getNumberOrVariable(cmd.timeout)
+
 	if !ok {
+		questCommandErrorArgType(cmd.name, qs, qt, cmd.timeout, "string", "integer")
+		return qs.handleError(q, qt)
+	}
+
+	if qt.waitTime < float32(limit) {
 		return false
 	}
 
-	qt.pc++
+	qs.printf(qt, "command '%s' has timed out, going to '%s'", cmd.name, cmd.target)
+
+	if !qs.jump(qt, cmd.target) {
+		questCommandErrorThing(cmd.name, "label or task", qs, qt, cmd.target)
+		return qs.handleError(q, qt)
+	}
+
 	return true
 }
 
+// jump moves the task past a label of the given name, or ends it and starts a task of such name instead
+func (qs *quest) jump(qt *questTask, target string) bool {
+	qt.waitTime = 0
+
+	for i, v := range qt.commands {
+		if v.name == "label" && len(v.args) == 1 && v.args[0] == target {
+			qt.pc = i + 1
+			return true
+		}
+	}
+
+	task := qs.findTask(target)
+
+	if task == nil {
+		return false
+	}
+
+	if task == qt {
+		qt.pc = 0
+		return true
+	}
+
+	task.pc = 0
+	task.isDone = false
+	task.isDormant = false
+	task.waitTime = 0
+
+	qt.pc = len(qt.commands)
+	return true
+}
+
+// handleError runs the ON_ERROR task and applies the quest's error policy,
+// it reports whether the task should carry on
+func (qs *quest) handleError(q *questManager, qt *questTask) bool {
+	err := qs.lastError
+	handler := qs.findTask(questErrorHandler)
+
+	if handler != nil && handler != qt {
+		qs.tasks[0].variables["$errorCode"] = makeQuestVarNumber(float64(err.code))
+		qs.tasks[0].variables["$errorMessage"] = makeQuestVarString(err.message)
+		qs.tasks[0].variables["$errorTask"] = makeQuestVarString(qt.name)
+
+		handler.pc = 0
+		handler.isDone = false
+
+		// errors raised while the quest is being added come before any budget is handed out
+		budget := handler.budget
+		handler.budget = questCommandBudget
+
+		for qs.processTask(q, handler) {
+			// error handler is being processed
+		}
+
+		handler.budget = budget
+
+		qs.activeQuestTask = qt
+	}
+
+	switch qs.onError {
+	case epSkip:
+		q.report(qs, qt, "error %d: %s, skipping the command", err.code, err.message)
+		qt.pc++
+		return true
+	case epFail:
+		q.report(qs, qt, "error %d: %s, failing the quest", err.code, err.message)
+		qs.setState(qsFailed)
+	default:
+		q.report(qs, qt, "error %d: %s, halting the task", err.code, err.message)
+	}
+
+	qt.isDone = true
+	return false
+}
+
+// startNextTask starts the task declared after the one at the given index, used by sequential quests
+func (qs *quest) startNextTask(idx int) {
+	for i := idx + 1; i < len(qs.tasks); i++ {
+		if v := &qs.tasks[i]; !v.isEvent && v.name != questErrorHandler {
+			v.isDormant = false
+			return
+		}
+	}
+}
+
+func (qs *quest) findTask(name string) *questTask {
+	for i := range qs.tasks {
+		if qs.tasks[i].name == name {
+			return &qs.tasks[i]
+		}
+	}
+
+	return nil
+}
+
 func (qs *quest) processTasks(q *questManager) {
 	for i := range qs.tasks {
 		v := &qs.tasks[i]
 
-		if v.isDone || v.isEvent {
+		if v.isDone || v.isEvent || v.isDormant {
 			continue
 		}
 
@@ -242,6 +498,14 @@ func (qs *quest) processTasks(q *questManager) {
 			// task is being processed
 		}
 
+		if v.isDone {
+			qs.logTaskTelemetry(v)
+		}
+
+		if qs.sequential && v.isDone && i > 0 {
+			qs.startNextTask(i)
+		}
+
 		state := 0
 
 		if v.isDone {
@@ -252,28 +516,62 @@ func (qs *quest) processTasks(q *questManager) {
 	}
 }
 
-func (qs *quest) callEvent(q *questManager, name string, args []float64) {
+func (qs *quest) callEvent(q *questManager, name string, args []questVar) {
+	received := false
+
 	for i := range qs.tasks {
 		v := &qs.tasks[i]
 
-		if v.name != name {
+		if v.name == questErrorHandler || !matchEventName(v, name) {
 			continue
 		}
 
+		if !received {
+			qs.logEventTelemetry(name)
+			received = true
+		}
+
 		v.isDone = false
+		v.pc = 0
 		v.eventArgs = args[:]
+		v.variables["$event"] = makeQuestVarString(name)
+
+		budget := v.budget
+		v.budget = questCommandBudget
 
 		for qs.processTask(q, v) {
 			// task is being processed
 		}
+
+		if v.budgetExhausted && !v.isDone {
+			q.report(qs, v, "event was interrupted after %d commands", questCommandBudget)
+			v.budgetExhausted = false
+		}
+
+		v.budget = budget
+	}
+}
+
+// matchEventName checks whether the task handles the event, event tasks can use wildcards like 'enemy.*'
+func matchEventName(qt *questTask, name string) bool {
+	if qt.name == name {
+		return true
 	}
+
+	if !qt.isEvent {
+		return false
+	}
+
+	ok, err := path.Match(qt.name, name)
+	return ok && err == nil
 }
 
 func (qs *quest) processVariables() {
 	qt := qs.activeQuestTask
 	qs.activeQuestTask = &qs.tasks[0]
-	qs.setVariable("$random", float64(rand.Int()))
-	qs.setVariable("$frandom", rand.Float64())
+	// refreshed every frame, drawing them from the quest's generator would tie its rolls to frame timing
+	qs.setVariable("$random", float64(simulationRand.Int()))
+	qs.setVariable("$frandom", simulationRand.Float64())
 	qs.setVariable("$step", float64(stepCounter))
 	qs.setVariable("$time", float64(rl.GetTime()))
 
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"testing"
+)
+
+const errorTestQuest = `TITLE: Errors
++ONERROR skip
+
+QST:
+
+stop missing
+setvar after 1
+
+ON_ERROR:
+    setvar world.lastErrorCode $errorCode
+`
+
+func TestQuestEntryPointErrorRunsHandler(t *testing.T) {
+	withQuestFiles(t, map[string]string{
+		"quests/errors.qst": errorTestQuest,
+	})
+
+	q := &currentGameMode.quests
+	_, reason, id := q.addQuest("errors", nil)
+	qs := q.findQuest(id)
+
+	if qs == nil {
+		t.Fatalf("quest could not be added: %s", reason)
+	}
+
+	if val, ok := currentGameMode.world.getNumber("world.lastErrorCode"); !ok || val != qeNotFound {
+		t.Errorf("got world.lastErrorCode = %v, want %d", val, qeNotFound)
+	}
+
+	if val, ok := qs.getVariable("after"); !ok || val != 1 {
+		t.Errorf("got after = %v, want the entry point to carry on", val)
+	}
+}
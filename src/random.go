@@ -0,0 +1,71 @@
+package main
+
+/*
+	Seeded randomness
+
+	Every quest draws from its own generator seeded by the game seed plus the quest's ID,
+	its state is a single number kept along with the quest in the save. Only commands of the
+	'Random' group draw from it, so a quest rolls the same no matter how many frames it waits.
+	Water, particles and the '$random' and '$frandom' variables, refreshed every frame, use
+	a separate simulation stream, so effects on the screen don't change what quests roll.
+
+	The game seed is random unless it's set by '-seed', it's printed to the log
+	so a playtest can be replayed.
+*/
+
+import (
+	"log"
+	"math/rand"
+	"time"
+)
+
+// simulationSeedSalt separates the simulation stream from quests seeded by the same game seed
+const simulationSeedSalt = 0x5DEECE66D
+
+var (
+	gameSeed       int64
+	simulationRand = rand.New(&randSource{})
+)
+
+// randSource is a splitmix64 generator, unlike sources of math/rand its whole state can be saved
+type randSource struct {
+	state uint64
+}
+
+func newRandSource(seed int64) randSource {
+	return randSource{state: uint64(seed)}
+}
+
+func (s *randSource) Seed(seed int64) {
+	s.state = uint64(seed)
+}
+
+func (s *randSource) Uint64() uint64 {
+	s.state += 0x9E3779B97F4A7C15
+	z := s.state
+	z = (z ^ (z >> 30)) * 0xBF58476D1CE4E5B9
+	z = (z ^ (z >> 27)) * 0x94D049BB133111EB
+	return z ^ (z >> 31)
+}
+
+func (s *randSource) Int63() int64 {
+	return int64(s.Uint64() >> 1)
+}
+
+// seedRandomness sets up the game seed and the simulation stream, a zero seed picks a random one
+func seedRandomness(seed int64) {
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	gameSeed = seed
+	src := newRandSource(seed ^ simulationSeedSalt)
+	simulationRand = rand.New(&src)
+
+	log.Printf("Game seed: %d\n", seed)
+}
+
+// rand returns the quest's own generator
+func (qs *quest) rand() *rand.Rand {
+	return rand.New(&qs.rng)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"testing"
+)
+
+const diceTestQuest = `TITLE: Dice
+
+QST:
+
+setvar ready 0
+when ready
+randint first 1 100
+randint second 1 100
+pick third 1 2 3 4 5 6
+`
+
+// rollAfter starts the dice quest with the given seed and lets it wait for a number of frames before rolling
+func rollAfter(t *testing.T, seed int64, frames int) []float64 {
+	withQuestFiles(t, map[string]string{
+		"quests/dice.qst": diceTestQuest,
+	})
+
+	// generators are seeded by the quest's ID too
+	ids := globalIDCounter
+	globalIDCounter = 1
+
+	t.Cleanup(func() {
+		globalIDCounter = ids
+	})
+
+	q := &currentGameMode.quests
+	q.seed = seed
+	_, reason, id := q.addQuest("dice", nil)
+
+	if q.findQuest(id) == nil {
+		t.Fatalf("quest could not be added: %s", reason)
+	}
+
+	for i := 0; i < frames; i++ {
+		q.processQuests()
+	}
+
+	qs := q.findQuest(id)
+	qs.setVariable("ready", 1)
+	q.processQuests()
+
+	res := []float64{}
+
+	for _, v := range []string{"first", "second", "third"} {
+		val, ok := qs.getVariable(v)
+
+		if !ok {
+			t.Fatalf("'%s' was not rolled after %d frames", v, frames)
+		}
+
+		res = append(res, val)
+	}
+
+	return res
+}
+
+func TestQuestRollsIgnoreFrameCount(t *testing.T) {
+	want := rollAfter(t, 1234, 0)
+
+	for _, frames := range []int{1, 7, 60} {
+		got := rollAfter(t, 1234, frames)
+
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("got rolls %v after %d frames, want %v", got, frames, want)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,262 @@
+package telemetry
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// States of quests and stages as they're logged
+const (
+	StateInProgress = "in progress"
+	StateFinished   = "finished"
+	StateFailed     = "failed"
+	StateAbandoned  = "abandoned"
+)
+
+// Report summarizes how players went through the quests
+type Report struct {
+	Sessions int
+	Quests   []QuestReport
+}
+
+// QuestReport summarizes all runs of a quest template
+type QuestReport struct {
+	Name      string
+	Started   int
+	Finished  int
+	Failed    int
+	Abandoned int
+	Stages    []StageReport
+	DropOffs  []DropOff
+}
+
+// StageReport summarizes a stage, times are in seconds of game time
+type StageReport struct {
+	ID       int
+	Reached  int
+	Finished int
+	Failed   int
+
+	// Median time between reaching and resolving the stage, out of Samples runs seen doing both
+	Median  float32
+	Samples int
+}
+
+// DropOff is the last point reached by players who neither finished nor failed the quest, abandoned quests included
+type DropOff struct {
+	Point string
+	Count int
+}
+
+type questRun struct {
+	name   string
+	state  string
+	stages map[int]float32
+	point  string
+}
+
+type stageKey struct {
+	quest string
+	id    int
+}
+
+// CompletionRate returns the share of started quests that were finished
+func (r *QuestReport) CompletionRate() float64 {
+	if r.Started == 0 {
+		return 0
+	}
+
+	return float64(r.Finished) / float64(r.Started)
+}
+
+// Analyze combines events of any number of sessions, the events of a session have to be in the order they were logged.
+// A quest kept in a save continues its run in later sessions, events without a run are told apart by their session and quest ID.
+func Analyze(events []Event) Report {
+	sessions := map[string]bool{}
+	runs := map[string]*questRun{}
+	order := []string{}
+	stages := map[stageKey]*StageReport{}
+	durations := map[stageKey][]float32{}
+
+	for _, e := range events {
+		sessions[e.Session] = true
+
+		if e.Quest == "" {
+			continue
+		}
+
+		id := e.Run
+
+		if id == "" {
+			id = fmt.Sprintf("%s/%d", e.Session, e.QuestID)
+		}
+
+		run, ok := runs[id]
+
+		if !ok {
+			// runs whose start isn't among the logs still count as started
+			run = &questRun{
+				name:   e.Quest,
+				state:  StateInProgress,
+				stages: map[int]float32{},
+				point:  "start",
+			}
+
+			runs[id] = run
+			order = append(order, id)
+		}
+
+		switch e.Kind {
+		case KindStageChanged:
+			key := stageKey{quest: run.name, id: e.Stage}
+			sta, ok := stages[key]
+
+			if !ok {
+				sta = &StageReport{ID: e.Stage}
+				stages[key] = sta
+			}
+
+			switch e.State {
+			case StateInProgress:
+				sta.Reached++
+				run.stages[e.Stage] = e.GameTime
+			case StateFinished, StateFailed:
+				if e.State == StateFinished {
+					sta.Finished++
+				} else {
+					sta.Failed++
+				}
+
+				if start, ok := run.stages[e.Stage]; ok {
+					durations[key] = append(durations[key], e.GameTime-start)
+					delete(run.stages, e.Stage)
+				}
+			}
+
+			run.point = fmt.Sprintf("stage %d %s", e.Stage, e.State)
+		case KindTaskDone:
+			run.point = fmt.Sprintf("task %s done", e.Task)
+		case KindQuestFinished:
+			run.state = StateFinished
+		case KindQuestFailed:
+			run.state = StateFailed
+		case KindQuestAbandoned:
+			run.state = StateAbandoned
+		}
+	}
+
+	byName := map[string]*QuestReport{}
+	names := []string{}
+	dropOffs := map[string]map[string]int{}
+
+	for _, id := range order {
+		run := runs[id]
+		qr, ok := byName[run.name]
+
+		if !ok {
+			qr = &QuestReport{Name: run.name}
+			byName[run.name] = qr
+			names = append(names, run.name)
+			dropOffs[run.name] = map[string]int{}
+		}
+
+		qr.Started++
+
+		switch run.state {
+		case StateFinished:
+			qr.Finished++
+		case StateFailed:
+			qr.Failed++
+		case StateAbandoned:
+			qr.Abandoned++
+			dropOffs[run.name][run.point]++
+		default:
+			dropOffs[run.name][run.point]++
+		}
+	}
+
+	sort.Strings(names)
+	res := Report{Sessions: len(sessions)}
+
+	for _, name := range names {
+		qr := byName[name]
+
+		for k, v := range stages {
+			if k.quest != name {
+				continue
+			}
+
+			v.Median = median(durations[k])
+			v.Samples = len(durations[k])
+			qr.Stages = append(qr.Stages, *v)
+		}
+
+		sort.Slice(qr.Stages, func(i, j int) bool {
+			return qr.Stages[i].ID < qr.Stages[j].ID
+		})
+
+		for k, v := range dropOffs[name] {
+			qr.DropOffs = append(qr.DropOffs, DropOff{Point: k, Count: v})
+		}
+
+		sort.Slice(qr.DropOffs, func(i, j int) bool {
+			if qr.DropOffs[i].Count != qr.DropOffs[j].Count {
+				return qr.DropOffs[i].Count > qr.DropOffs[j].Count
+			}
+
+			return qr.DropOffs[i].Point < qr.DropOffs[j].Point
+		})
+
+		res.Quests = append(res.Quests, *qr)
+	}
+
+	return res
+}
+
+func median(values []float32) float32 {
+	if len(values) == 0 {
+		return 0
+	}
+
+	v := append([]float32{}, values...)
+	sort.Slice(v, func(i, j int) bool { return v[i] < v[j] })
+
+	if n := len(v); n%2 == 0 {
+		return (v[n/2-1] + v[n/2]) / 2
+	}
+
+	return v[len(v)/2]
+}
+
+// WriteReport prints the report as plain text
+func WriteReport(w io.Writer, r Report) {
+	fmt.Fprintf(w, "%d sessions, %d quests\n", r.Sessions, len(r.Quests))
+
+	for _, q := range r.Quests {
+		fmt.Fprintf(w, "\nQuest '%s': %d started, %d finished, %d failed, %d abandoned, %.1f%% completed\n",
+			q.Name, q.Started, q.Finished, q.Failed, q.Abandoned, q.CompletionRate()*100)
+
+		if len(q.Stages) > 0 {
+			fmt.Fprintf(w, "  %8s %8s %8s %8s %10s\n", "stage", "reached", "finished", "failed", "median s")
+		}
+
+		for _, s := range q.Stages {
+			median := "-"
+
+			if s.Samples > 0 {
+				median = fmt.Sprintf("%.1f", s.Median)
+			}
+
+			fmt.Fprintf(w, "  %8d %8d %8d %8d %10s\n", s.ID, s.Reached, s.Finished, s.Failed, median)
+		}
+
+		if len(q.DropOffs) > 0 {
+			fmt.Fprintf(w, "  dropped off at:\n")
+		}
+
+		for _, d := range q.DropOffs {
+			fmt.Fprintf(w, "    %s: %d\n", d.Point, d.Count)
+		}
+	}
+}
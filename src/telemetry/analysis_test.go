@@ -0,0 +1,134 @@
+package telemetry
+
+import (
+	"reflect"
+	"testing"
+)
+
+func questEvent(session, run, kind string, gameTime float32) Event {
+	return Event{Session: session, Run: run, Kind: kind, Quest: "delivery", QuestID: 5, GameTime: gameTime}
+}
+
+func stageEvent(session, run string, stage int, state string, gameTime float32) Event {
+	e := questEvent(session, run, KindStageChanged, gameTime)
+	e.Stage = stage
+	e.State = state
+	return e
+}
+
+func TestAnalyze(t *testing.T) {
+	tests := []struct {
+		name   string
+		events []Event
+		want   Report
+	}{
+		{
+			name:   "no events",
+			events: []Event{},
+			want:   Report{},
+		},
+		{
+			name: "finished run",
+			events: []Event{
+				questEvent("a", "a/5", KindQuestAdded, 0),
+				stageEvent("a", "a/5", 100, StateInProgress, 10),
+				stageEvent("a", "a/5", 100, StateFinished, 40),
+				questEvent("a", "a/5", KindQuestFinished, 40),
+			},
+			want: Report{Sessions: 1, Quests: []QuestReport{{
+				Name: "delivery", Started: 1, Finished: 1,
+				Stages: []StageReport{{ID: 100, Reached: 1, Finished: 1, Median: 30, Samples: 1}},
+			}}},
+		},
+		{
+			name: "run continued from a save",
+			events: []Event{
+				questEvent("a", "a/5", KindQuestAdded, 0),
+				stageEvent("a", "a/5", 100, StateInProgress, 10),
+				stageEvent("b", "a/5", 100, StateFinished, 25),
+				questEvent("b", "a/5", KindQuestFinished, 25),
+			},
+			want: Report{Sessions: 2, Quests: []QuestReport{{
+				Name: "delivery", Started: 1, Finished: 1,
+				Stages: []StageReport{{ID: 100, Reached: 1, Finished: 1, Median: 15, Samples: 1}},
+			}}},
+		},
+		{
+			name: "runs without a run ID are told apart by their session",
+			events: []Event{
+				questEvent("a", "", KindQuestAdded, 0),
+				questEvent("b", "", KindQuestAdded, 0),
+				questEvent("b", "", KindQuestFailed, 5),
+			},
+			want: Report{Sessions: 2, Quests: []QuestReport{{
+				Name: "delivery", Started: 2, Failed: 1,
+				DropOffs: []DropOff{{Point: "start", Count: 1}},
+			}}},
+		},
+		{
+			name: "abandoned and dropped off runs",
+			events: []Event{
+				questEvent("a", "a/5", KindQuestAdded, 0),
+				stageEvent("a", "a/5", 100, StateInProgress, 10),
+				questEvent("a", "a/5", KindQuestAbandoned, 20),
+				questEvent("b", "b/5", KindQuestAdded, 0),
+				stageEvent("b", "b/5", 100, StateInProgress, 10),
+				questEvent("c", "c/5", KindQuestAdded, 0),
+				{Session: "c", Run: "c/5", Kind: KindTaskDone, Quest: "delivery", QuestID: 5, Task: "talk"},
+			},
+			want: Report{Sessions: 3, Quests: []QuestReport{{
+				Name: "delivery", Started: 3, Abandoned: 1,
+				Stages: []StageReport{{ID: 100, Reached: 2}},
+				DropOffs: []DropOff{
+					{Point: "stage 100 in progress", Count: 2},
+					{Point: "task talk done", Count: 1},
+				},
+			}}},
+		},
+		{
+			name: "median of several runs",
+			events: []Event{
+				stageEvent("a", "a/1", 100, StateInProgress, 0),
+				stageEvent("a", "a/1", 100, StateFinished, 10),
+				stageEvent("a", "a/2", 100, StateInProgress, 0),
+				stageEvent("a", "a/2", 100, StateFailed, 20),
+				stageEvent("a", "a/3", 100, StateInProgress, 0),
+				stageEvent("a", "a/3", 100, StateFinished, 60),
+				stageEvent("a", "a/4", 100, StateInProgress, 0),
+				stageEvent("a", "a/4", 100, StateFinished, 70),
+			},
+			want: Report{Sessions: 1, Quests: []QuestReport{{
+				Name: "delivery", Started: 4,
+				Stages:   []StageReport{{ID: 100, Reached: 4, Finished: 3, Failed: 1, Median: 40, Samples: 4}},
+				DropOffs: []DropOff{{Point: "stage 100 finished", Count: 3}, {Point: "stage 100 failed", Count: 1}},
+			}}},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := Analyze(tc.events)
+
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCompletionRate(t *testing.T) {
+	tests := []struct {
+		report QuestReport
+		want   float64
+	}{
+		{QuestReport{}, 0},
+		{QuestReport{Started: 4, Finished: 1}, 0.25},
+		{QuestReport{Started: 2, Finished: 2}, 1},
+	}
+
+	for _, tc := range tests {
+		if got := tc.report.CompletionRate(); got != tc.want {
+			t.Errorf("got %v for %+v, want %v", got, tc.report, tc.want)
+		}
+	}
+}
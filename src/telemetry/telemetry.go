@@ -0,0 +1,110 @@
+package telemetry
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// Kinds of logged events
+const (
+	KindQuestAdded     = "quest_added"
+	KindStageChanged   = "stage_changed"
+	KindTaskDone       = "task_done"
+	KindEventReceived  = "event_received"
+	KindQuestFinished  = "quest_finished"
+	KindQuestFailed    = "quest_failed"
+	KindQuestAbandoned = "quest_abandoned"
+	KindDialogueChoice = "dialogue_choice"
+)
+
+// Event is a single line of the telemetry log
+type Event struct {
+	Time     time.Time `json:"time"`
+	Session  string    `json:"session"`
+	GameTime float32   `json:"gameTime"`
+	Kind     string    `json:"kind"`
+	Map      string    `json:"map,omitempty"`
+	X        float32   `json:"x"`
+	Y        float32   `json:"y"`
+
+	Quest   string `json:"quest,omitempty"`
+	QuestID int64  `json:"questId,omitempty"`
+	Run     string `json:"run,omitempty"`
+	Task    string `json:"task,omitempty"`
+	Stage   int    `json:"stage,omitempty"`
+	State   string `json:"state,omitempty"`
+	Event   string `json:"event,omitempty"`
+
+	Dialogue string `json:"dialogue,omitempty"`
+	Node     string `json:"node,omitempty"`
+	Choice   string `json:"choice,omitempty"`
+}
+
+// Log appends events of a single play session to a JSONL file
+type Log struct {
+	file    *os.File
+	enc     *json.Encoder
+	session string
+}
+
+// Open opens the log for appending, every run of the game starts a new session
+func Open(path string) (*Log, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &Log{
+		file:    f,
+		enc:     json.NewEncoder(f),
+		session: time.Now().UTC().Format("20060102T150405.000Z"),
+	}, nil
+}
+
+// Write stamps the event with the time and session and appends it to the log
+func (l *Log) Write(e Event) error {
+	e.Time = time.Now().UTC()
+	e.Session = l.session
+	return l.enc.Encode(e)
+}
+
+// Session returns the ID of the session the log writes
+func (l *Log) Session() string {
+	return l.session
+}
+
+// Close closes the log's file
+func (l *Log) Close() error {
+	return l.file.Close()
+}
+
+// Read parses events of a JSONL log, blank lines are skipped
+func Read(r io.Reader) ([]Event, error) {
+	res := []Event{}
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 64*1024), 1024*1024)
+	line := 0
+
+	for s.Scan() {
+		line++
+
+		if len(s.Bytes()) == 0 {
+			continue
+		}
+
+		var e Event
+
+		if err := json.Unmarshal(s.Bytes(), &e); err != nil {
+			return res, fmt.Errorf("line %d: %s", line, err)
+		}
+
+		res = append(res, e)
+	}
+
+	return res, s.Err()
+}
@@ -2,7 +2,6 @@ package main
 
 import (
 	"encoding/gob"
-	"math/rand"
 
 	rl "github.com/zaklaus/raylib-go/raylib"
 	"github.com/zaklaus/raylib-go/raymath"
@@ -75,7 +74,7 @@ func NewWater(o *core.Object) {
 		for _, v := range o.ContainedObjects {
 			other := v.Object
 
-			if rand.Int()%3 == 0 {
+			if simulationRand.Int()%3 == 0 {
 				xpos := int32(other.Position.X-o.Position.X) / waterTileSize
 				ypos := int32(other.Position.Y-o.Position.Y) / waterTileSize
 
@@ -203,8 +202,8 @@ func (w *water) updateWater() {
 
 			m := &w.energy[idx]
 
-			if rand.Int()%4 == 0 {
-				*m = rand.Float32() * waterNoise
+			if simulationRand.Int()%4 == 0 {
+				*m = simulationRand.Float32() * waterNoise
 			}
 
 			*m = core.ScalarLerp(
@@ -221,7 +220,7 @@ func (w *water) updateWater() {
 func pushWaterParticle(world *core.World, origin rl.Vector2) {
 	part := waterParticle{}
 
-	numParts := waterParticleMinCount + rand.Int()%int(waterParticleMaxCount-waterParticleMinCount)
+	numParts := waterParticleMinCount + simulationRand.Int()%int(waterParticleMaxCount-waterParticleMinCount)
 
 	for i := 0; i < numParts; i++ {
 		pos := origin
@@ -230,7 +229,7 @@ func pushWaterParticle(world *core.World, origin rl.Vector2) {
 			X: (posx*2 - 1) * waterParticleSpreadFactor,
 			Y: -waterParticleSplashForce,
 		}
-		col := core.Vec3ToColor(raymath.Vector3Lerp(core.ColorToVec3(waterCalm), core.ColorToVec3(waterWild), rand.Float32()))
+		col := core.Vec3ToColor(raymath.Vector3Lerp(core.ColorToVec3(waterCalm), core.ColorToVec3(waterWild), simulationRand.Float32()))
 
 		part.position = append(part.position, pos)
 		part.direction = append(part.direction, dir)
@@ -254,7 +253,7 @@ func updateWaterParticles() {
 			dy := float32(core.RoundFloatToInt32(d.Y))
 			dx := float32(core.RoundFloatToInt32(d.X))
 
-			if waterParticleEnableCollision && rand.Int()%3 == 0 {
+			if waterParticleEnableCollision && simulationRand.Int()%3 == 0 {
 				rect := rl.RectangleInt32{
 					X:      core.RoundFloatToInt32(p.X),
 					Y:      core.RoundFloatToInt32(p.Y),
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+
+	rl "github.com/zaklaus/raylib-go/raylib"
+)
+
+const (
+	// worldPrefix marks variables stored in the world state instead of a quest
+	worldPrefix = "world."
+)
+
+var (
+	worldFlagPattern = regexp.MustCompile(`world\.[A-Za-z0-9_.]+`)
+)
+
+// worldState is a global key/value store shared by quests, dialogues and scripts
+type worldState struct {
+	flags map[string]questVar
+}
+
+func makeWorldState() worldState {
+	return worldState{
+		flags: map[string]questVar{},
+	}
+}
+
+func isWorldFlag(name string) bool {
+	return strings.HasPrefix(name, worldPrefix)
+}
+
+func worldFlagName(name string) string {
+	return strings.TrimPrefix(name, worldPrefix)
+}
+
+func (w *worldState) reset() {
+	w.flags = map[string]questVar{}
+}
+
+// GobEncode saves the flags, the save can't reach them otherwise
+func (w worldState) GobEncode() ([]byte, error) {
+	return gobEncode(w.flags)
+}
+
+// GobDecode restores the flags from the save
+func (w *worldState) GobDecode(data []byte) error {
+	w.reset()
+	return gobDecode(data, &w.flags)
+}
+
+func (w *worldState) get(name string) (questVar, bool) {
+	val, ok := w.flags[worldFlagName(name)]
+	return val, ok
+}
+
+func (w *worldState) set(name string, val questVar) {
+	name = worldFlagName(name)
+
+	if w.flags == nil {
+		w.reset()
+	}
+
+	w.flags[name] = val
+
+	log.Printf("World flag '%s' was set to: %s", name, val.value.str())
+}
+
+func (w *worldState) getNumber(name string) (float64, bool) {
+	val, ok := w.get(name)
+
+	if !ok || val.kind != kindNumber {
+		return 0, false
+	}
+
+	return val.value.(*questVarNumber).value, true
+}
+
+func (w *worldState) getVector(name string) (rl.Vector2, bool) {
+	val, ok := w.get(name)
+
+	if !ok || val.kind != kindVector {
+		return rl.Vector2{}, false
+	}
+
+	return val.value.(*questVarVector).value, true
+}
+
+func (w *worldState) setNumber(name string, val float64) {
+	w.set(name, questVar{
+		kind:  kindNumber,
+		value: &questVarNumber{value: val},
+	})
+}
+
+func (w *worldState) setString(name string, val string) {
+	w.set(name, questVar{
+		kind:  kindString,
+		value: &questVarString{value: val},
+	})
+}
+
+func (w *worldState) setVector(name string, val rl.Vector2) {
+	w.set(name, questVar{
+		kind:  kindVector,
+		value: &questVarVector{value: val},
+	})
+}
+
+// resolveFlags replaces world flags within an expression by their values,
+// flags which are not set yet resolve to 0
+func (w *worldState) resolveFlags(expr string) string {
+	return worldFlagPattern.ReplaceAllStringFunc(expr, func(name string) string {
+		val, ok := w.get(name)
+
+		if !ok {
+			return "0"
+		}
+
+		return val.value.str()
+	})
+}
+
+// processText substitutes '%world.name%' placeholders
+func (w *worldState) processText(content string) string {
+	for k, v := range w.flags {
+		content = strings.ReplaceAll(content, fmt.Sprintf("%%%s%s%%", worldPrefix, k), v.value.str())
+	}
+
+	return content
+}
@@ -0,0 +1,32 @@
+package yarn
+
+// Dialogue mirrors the game's dialogue structure as stored in assets/texts
+type Dialogue struct {
+	ID         string    `yaml:"id,omitempty"`
+	Name       string    `yaml:"name,omitempty"`
+	AvatarFile string    `yaml:"avatar,omitempty"`
+	Text       string    `yaml:"text,omitempty"`
+	Choices    []*Choice `yaml:"choices,omitempty"`
+	Event      string    `yaml:"event,omitempty"`
+	EventArgs  string    `yaml:"eventArgs,omitempty"`
+	SkipPrompt bool      `yaml:"skipPrompt,omitempty"`
+	Silent     bool      `yaml:"silent,omitempty"`
+
+	Timeout       float32 `yaml:"timeout,omitempty"`
+	DefaultChoice int     `yaml:"defaultChoice,omitempty"`
+	AutoAdvance   float32 `yaml:"autoAdvance,omitempty"`
+
+	Set   []string    `yaml:"set,omitempty"`
+	If    string      `yaml:"if,omitempty"`
+	Else  *Dialogue   `yaml:"else,omitempty"`
+	Jump  string      `yaml:"jump,omitempty"`
+	Next  *Dialogue   `yaml:"next,omitempty"`
+	Nodes []*Dialogue `yaml:"nodes,omitempty"`
+}
+
+// Choice is a selection from dialogue branches
+type Choice struct {
+	Text string    `yaml:"text"`
+	If   string    `yaml:"if,omitempty"`
+	Next *Dialogue `yaml:"next,omitempty"`
+}
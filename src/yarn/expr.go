@@ -0,0 +1,177 @@
+package yarn
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+var exprOperators = map[string]string{
+	"is":  "==",
+	"eq":  "==",
+	"neq": "!=",
+	"gt":  ">",
+	"lt":  "<",
+	"gte": ">=",
+	"lte": "<=",
+	"and": "&&",
+	"or":  "||",
+	"not": "!",
+}
+
+var exprConstants = map[string]string{
+	"true":  "1",
+	"false": "0",
+}
+
+// convertExpr translates a Yarn expression into the form evaluated by the game,
+// variables are written as '[name]' so that scoped names like 'QUEST.var' survive
+func convertExpr(src string) (string, error) {
+	out, err := tokenizeExpr(src)
+
+	if err != nil {
+		return "", err
+	}
+
+	return strings.Join(out, " "), nil
+}
+
+// convertCondition translates a Yarn condition, see convertExpr
+func convertCondition(src string) (string, error) {
+	out, err := tokenizeExpr(src)
+
+	if err != nil {
+		return "", err
+	}
+
+	return strings.Join(truthify(out), " "), nil
+}
+
+func tokenizeExpr(src string) ([]string, error) {
+	var out []string
+	rs := []rune(strings.TrimSpace(src))
+
+	if len(rs) == 0 {
+		return nil, fmt.Errorf("expression is empty")
+	}
+
+	for i := 0; i < len(rs); {
+		r := rs[i]
+
+		switch {
+		case unicode.IsSpace(r):
+			i++
+
+		case r == '$':
+			j := i + 1
+
+			for j < len(rs) && isNameChar(rs[j]) {
+				j++
+			}
+
+			if j == i+1 {
+				return nil, fmt.Errorf("variable name expected at '%s'", string(rs[i:]))
+			}
+
+			out = append(out, fmt.Sprintf("[%s]", string(rs[i+1:j])))
+			i = j
+
+		case unicode.IsDigit(r) || r == '.':
+			j := i
+
+			for j < len(rs) && (unicode.IsDigit(rs[j]) || rs[j] == '.') {
+				j++
+			}
+
+			out = append(out, string(rs[i:j]))
+			i = j
+
+		case unicode.IsLetter(r):
+			j := i
+
+			for j < len(rs) && (unicode.IsLetter(rs[j]) || unicode.IsDigit(rs[j]) || rs[j] == '_') {
+				j++
+			}
+
+			word := strings.ToLower(string(rs[i:j]))
+
+			if op, ok := exprOperators[word]; ok {
+				out = append(out, op)
+			} else if c, ok := exprConstants[word]; ok {
+				out = append(out, c)
+			} else {
+				return nil, fmt.Errorf("unknown word '%s' in expression, variables start with '$'", word)
+			}
+
+			i = j
+
+		case r == '"':
+			j := i + 1
+
+			for j < len(rs) && rs[j] != '"' {
+				j++
+			}
+
+			str := string(rs[i+1 : j])
+
+			if j >= len(rs) || strings.ContainsRune(str, '\'') {
+				return nil, fmt.Errorf("invalid string value '%s'", string(rs[i:]))
+			}
+
+			out = append(out, fmt.Sprintf("'%s'", str))
+			i = j + 1
+
+		default:
+			j := i + 1
+
+			if j < len(rs) && strings.ContainsRune("=&|<>", rs[j]) && strings.ContainsRune("=!<>&|", r) {
+				j++
+			}
+
+			op := string(rs[i:j])
+
+			if !strings.ContainsAny(op, "+-*/%()=!<>&|") {
+				return nil, fmt.Errorf("unexpected character '%s' in expression", op)
+			}
+
+			out = append(out, op)
+			i = j
+		}
+	}
+
+	return out, nil
+}
+
+// truthify turns bare operands of logical operators into comparisons,
+// since variables hold numbers and logical operators only accept booleans
+func truthify(tokens []string) []string {
+	res := []string{}
+
+	for i, tk := range tokens {
+		prev, next := "", ""
+
+		if i > 0 {
+			prev = tokens[i-1]
+		}
+
+		if i < len(tokens)-1 {
+			next = tokens[i+1]
+		}
+
+		isOperand := strings.HasPrefix(tk, "[") || (tk != "" && (unicode.IsDigit(rune(tk[0])) || tk[0] == '.'))
+
+		if isOperand &&
+			(prev == "" || prev == "!" || prev == "&&" || prev == "||" || prev == "(") &&
+			(next == "" || next == "&&" || next == "||" || next == ")") {
+			tk = fmt.Sprintf("(%s != 0)", tk)
+		}
+
+		res = append(res, tk)
+	}
+
+	return res
+}
+
+func isNameChar(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '.'
+}
@@ -0,0 +1,82 @@
+package yarn
+
+import (
+	"testing"
+)
+
+func TestConvertExpr(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+		err  string
+	}{
+		{src: "1", want: "1"},
+		{src: "$gold + 10", want: "[gold] + 10"},
+		{src: "$EXAMPLE.healCount * 2", want: "[EXAMPLE.healCount] * 2"},
+		{src: "($a-1)/2", want: "( [a] - 1 ) / 2"},
+		{src: "true", want: "1"},
+		{src: "FALSE", want: "0"},
+		{src: `"sword"`, want: "'sword'"},
+		{src: "$a is 1", want: "[a] == 1"},
+		{src: "$a eq 1 or $b neq 2", want: "[a] == 1 || [b] != 2"},
+		{src: "$a gte 1 and $b lte 2", want: "[a] >= 1 && [b] <= 2"},
+		{src: "$a gt 1 and $b lt 2", want: "[a] > 1 && [b] < 2"},
+		{src: "$a >= 1 && $b != 2", want: "[a] >= 1 && [b] != 2"},
+		{src: "not $a", want: "! [a]"},
+		{src: "", err: "expression is empty"},
+		{src: "$ + 1", err: "variable name expected at '$ + 1'"},
+		{src: "gold + 1", err: "unknown word 'gold' in expression, variables start with '$'"},
+		{src: `"sword`, err: `invalid string value '"sword'`},
+		{src: `"it's"`, err: `invalid string value '"it's"'`},
+		{src: "$a ; 1", err: "unexpected character ';' in expression"},
+	}
+
+	for _, tc := range tests {
+		got, err := convertExpr(tc.src)
+
+		if tc.err != "" {
+			if err == nil || err.Error() != tc.err {
+				t.Errorf("convertExpr(%q) gave error '%v', want '%s'", tc.src, err, tc.err)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("convertExpr(%q) failed: %s", tc.src, err)
+			continue
+		}
+
+		if got != tc.want {
+			t.Errorf("convertExpr(%q) = '%s', want '%s'", tc.src, got, tc.want)
+		}
+	}
+}
+
+func TestConvertCondition(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"$a", "([a] != 0)"},
+		{"not $a", "! ([a] != 0)"},
+		{"$a and $b", "([a] != 0) && ([b] != 0)"},
+		{"$a or $b is 2", "([a] != 0) || [b] == 2"},
+		{"($a) and 1", "( ([a] != 0) ) && (1 != 0)"},
+		{"$a gt 1", "[a] > 1"},
+		{"$a + $b", "[a] + [b]"},
+	}
+
+	for _, tc := range tests {
+		got, err := convertCondition(tc.src)
+
+		if err != nil {
+			t.Errorf("convertCondition(%q) failed: %s", tc.src, err)
+			continue
+		}
+
+		if got != tc.want {
+			t.Errorf("convertCondition(%q) = '%s', want '%s'", tc.src, got, tc.want)
+		}
+	}
+}
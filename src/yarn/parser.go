@@ -0,0 +1,522 @@
+package yarn
+
+/*
+	Yarn Spinner-like script parser
+
+	Supported syntax:
+	- node headers ('title: Name') terminated by '---', node bodies terminated by '==='
+	- lines ('Speaker: text' or plain 'text'), optionally followed by hashtags
+	  '#timeout:<seconds>', '#default:<choice>' and '#auto:<seconds>'
+	- shortcut options ('-> text [<<if cond>>]') with indented bodies
+	- link options ('[[text|Node]]') and jumps ('[[Node]]', '<<jump Node>>')
+	- commands '<<set $var to expr>>', '<<if>>/<<elseif>>/<<else>>/<<endif>>',
+	  '<<event name args...>>' and '<<stop>>'
+*/
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const (
+	stLine = iota
+	stOptions
+	stSet
+	stEvent
+	stJump
+	stStop
+	stIf
+)
+
+type statement struct {
+	kind int
+	line int
+
+	speaker string
+	text    string
+
+	timeout       float32
+	defaultChoice int
+	autoAdvance   float32
+	name          string
+	expr          string
+	target        string
+
+	options  []option
+	branches []branch
+}
+
+type option struct {
+	text   string
+	cond   string
+	target string
+	body   []statement
+}
+
+type branch struct {
+	cond string
+	body []statement
+}
+
+type node struct {
+	title string
+	line  int
+	body  []statement
+}
+
+type srcLine struct {
+	num    int
+	indent int
+	text   string
+}
+
+type parser struct {
+	file  string
+	lines []srcLine
+	pos   int
+}
+
+func (p *parser) errorf(line int, format string, args ...interface{}) error {
+	return fmt.Errorf("%s:%d: %s", p.file, line, fmt.Sprintf(format, args...))
+}
+
+func splitLines(data []byte) []srcLine {
+	res := []srcLine{}
+
+	for idx, raw := range strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n") {
+		text := stripComment(raw)
+		trimmed := strings.TrimLeftFunc(text, unicode.IsSpace)
+
+		if strings.TrimSpace(trimmed) == "" {
+			continue
+		}
+
+		indent := 0
+
+		for _, r := range text[:len(text)-len(trimmed)] {
+			if r == '\t' {
+				indent += 4
+			} else {
+				indent++
+			}
+		}
+
+		res = append(res, srcLine{
+			num:    idx + 1,
+			indent: indent,
+			text:   strings.TrimSpace(trimmed),
+		})
+	}
+
+	return res
+}
+
+func stripComment(s string) string {
+	if idx := strings.Index(s, "//"); idx == 0 || (idx > 0 && unicode.IsSpace(rune(s[idx-1]))) {
+		return s[:idx]
+	}
+
+	return s
+}
+
+func (p *parser) parseNodes() ([]*node, error) {
+	nodes := []*node{}
+
+	for p.pos < len(p.lines) {
+		n := &node{line: p.lines[p.pos].num}
+
+		// header
+		for ; p.pos < len(p.lines) && p.lines[p.pos].text != "---"; p.pos++ {
+			l := p.lines[p.pos]
+			sep := strings.Index(l.text, ":")
+
+			if sep == -1 {
+				return nil, p.errorf(l.num, "invalid header line '%s'", l.text)
+			}
+
+			if strings.TrimSpace(l.text[:sep]) == "title" {
+				n.title = strings.TrimSpace(l.text[sep+1:])
+			}
+		}
+
+		if p.pos >= len(p.lines) {
+			return nil, p.errorf(n.line, "node header is not terminated by '---'")
+		}
+
+		if n.title == "" {
+			return nil, p.errorf(n.line, "node has no title")
+		}
+
+		p.pos++
+
+		// body
+		start := p.pos
+
+		for p.pos < len(p.lines) && p.lines[p.pos].text != "===" {
+			p.pos++
+		}
+
+		if p.pos >= len(p.lines) {
+			return nil, p.errorf(n.line, "node '%s' is not terminated by '==='", n.title)
+		}
+
+		body := &parser{
+			file:  p.file,
+			lines: p.lines[start:p.pos],
+		}
+
+		stmts, term, err := body.block(0, false)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if term != "" {
+			return nil, p.errorf(body.lines[body.pos].num, "unexpected '<<%s>>'", term)
+		}
+
+		n.body = stmts
+		nodes = append(nodes, n)
+		p.pos++
+	}
+
+	return nodes, nil
+}
+
+// block parses statements until the indentation drops below indent or an if-branch terminator is found
+func (p *parser) block(indent int, inIf bool) ([]statement, string, error) {
+	stmts := []statement{}
+
+	for p.pos < len(p.lines) {
+		l := p.lines[p.pos]
+
+		if l.indent < indent {
+			break
+		}
+
+		cmd, args, isCmd := parseCommand(l.text)
+
+		if isCmd && (cmd == "elseif" || cmd == "else" || cmd == "endif") {
+			if !inIf {
+				return nil, "", p.errorf(l.num, "unexpected '<<%s>>'", cmd)
+			}
+
+			return stmts, cmd, nil
+		}
+
+		if isOption(l.text) {
+			st, err := p.parseOptions(l.indent)
+
+			if err != nil {
+				return nil, "", err
+			}
+
+			stmts = append(stmts, st)
+			continue
+		}
+
+		if strings.HasPrefix(l.text, "[[") {
+			target, err := p.parseLink(l)
+
+			if err != nil {
+				return nil, "", err
+			}
+
+			stmts = append(stmts, statement{kind: stJump, line: l.num, target: target})
+			p.pos++
+			continue
+		}
+
+		if !isCmd {
+			text, tags := splitTags(l.text)
+			st := statement{kind: stLine, line: l.num}
+			st.speaker, st.text = splitSpeaker(text)
+
+			if err := st.applyTags(tags); err != nil {
+				return nil, "", p.errorf(l.num, "%s", err)
+			}
+
+			stmts = append(stmts, st)
+			p.pos++
+			continue
+		}
+
+		p.pos++
+
+		switch cmd {
+		case "set":
+			name, expr, err := parseAssignment(args)
+
+			if err != nil {
+				return nil, "", p.errorf(l.num, "%s", err)
+			}
+
+			stmts = append(stmts, statement{kind: stSet, line: l.num, name: name, expr: expr})
+
+		case "event":
+			fields := strings.Fields(args)
+
+			if len(fields) == 0 {
+				return nil, "", p.errorf(l.num, "'<<event>>' needs an event name")
+			}
+
+			stmts = append(stmts, statement{
+				kind: stEvent,
+				line: l.num,
+				name: fields[0],
+				text: strings.TrimSpace(strings.TrimPrefix(args, fields[0])),
+			})
+
+		case "jump":
+			if args == "" {
+				return nil, "", p.errorf(l.num, "'<<jump>>' needs a node name")
+			}
+
+			stmts = append(stmts, statement{kind: stJump, line: l.num, target: args})
+
+		case "stop":
+			stmts = append(stmts, statement{kind: stStop, line: l.num})
+
+		case "if":
+			st, err := p.parseIf(l, args)
+
+			if err != nil {
+				return nil, "", err
+			}
+
+			stmts = append(stmts, st)
+
+		default:
+			return nil, "", p.errorf(l.num, "unsupported command '<<%s>>'", cmd)
+		}
+	}
+
+	if inIf {
+		return nil, "", p.errorf(p.lastLine(), "missing '<<endif>>'")
+	}
+
+	return stmts, "", nil
+}
+
+func (p *parser) parseIf(l srcLine, cond string) (statement, error) {
+	st := statement{kind: stIf, line: l.num}
+
+	if cond == "" {
+		return st, p.errorf(l.num, "'<<if>>' needs a condition")
+	}
+
+	for {
+		expr := ""
+
+		if cond != "" {
+			var err error
+			expr, err = convertCondition(cond)
+
+			if err != nil {
+				return st, p.errorf(l.num, "%s", err)
+			}
+		}
+
+		body, term, err := p.block(l.indent, true)
+
+		if err != nil {
+			return st, err
+		}
+
+		st.branches = append(st.branches, branch{cond: expr, body: body})
+
+		termLine := p.lines[p.pos]
+		_, args, _ := parseCommand(termLine.text)
+		p.pos++
+
+		switch term {
+		case "endif":
+			return st, nil
+		case "else":
+			if cond == "" {
+				return st, p.errorf(termLine.num, "duplicate '<<else>>'")
+			}
+
+			cond = ""
+		case "elseif":
+			if cond == "" {
+				return st, p.errorf(termLine.num, "'<<elseif>>' after '<<else>>'")
+			}
+
+			if args == "" {
+				return st, p.errorf(termLine.num, "'<<elseif>>' needs a condition")
+			}
+
+			cond = args
+		}
+
+		l = termLine
+	}
+}
+
+func (p *parser) parseOptions(indent int) (statement, error) {
+	st := statement{kind: stOptions, line: p.lines[p.pos].num}
+
+	for p.pos < len(p.lines) && p.lines[p.pos].indent == indent && isOption(p.lines[p.pos].text) {
+		l := p.lines[p.pos]
+		p.pos++
+
+		if strings.HasPrefix(l.text, "[[") {
+			inner := strings.TrimSuffix(strings.TrimPrefix(l.text, "[["), "]]")
+			sep := strings.LastIndex(inner, "|")
+
+			st.options = append(st.options, option{
+				text:   strings.TrimSpace(inner[:sep]),
+				target: strings.TrimSpace(inner[sep+1:]),
+			})
+			continue
+		}
+
+		opt := option{text: strings.TrimSpace(strings.TrimPrefix(l.text, "->"))}
+
+		if idx := strings.Index(opt.text, "<<"); idx != -1 {
+			cmd, args, ok := parseCommand(opt.text[idx:])
+
+			if !ok || cmd != "if" || args == "" {
+				return st, p.errorf(l.num, "options only accept an '<<if>>' condition")
+			}
+
+			cond, err := convertCondition(args)
+
+			if err != nil {
+				return st, p.errorf(l.num, "%s", err)
+			}
+
+			opt.text = strings.TrimSpace(opt.text[:idx])
+			opt.cond = cond
+		}
+
+		body, _, err := p.block(indent+1, false)
+
+		if err != nil {
+			return st, err
+		}
+
+		opt.body = body
+		st.options = append(st.options, opt)
+	}
+
+	return st, nil
+}
+
+func (p *parser) parseLink(l srcLine) (string, error) {
+	if !strings.HasSuffix(l.text, "]]") {
+		return "", p.errorf(l.num, "unterminated link '%s'", l.text)
+	}
+
+	return strings.TrimSpace(l.text[2 : len(l.text)-2]), nil
+}
+
+func (p *parser) lastLine() int {
+	if len(p.lines) == 0 {
+		return 0
+	}
+
+	return p.lines[len(p.lines)-1].num
+}
+
+func isOption(text string) bool {
+	return strings.HasPrefix(text, "->") ||
+		(strings.HasPrefix(text, "[[") && strings.HasSuffix(text, "]]") && strings.Contains(text, "|"))
+}
+
+func parseCommand(text string) (string, string, bool) {
+	if !strings.HasPrefix(text, "<<") || !strings.HasSuffix(text, ">>") {
+		return "", "", false
+	}
+
+	inner := strings.TrimSpace(text[2 : len(text)-2])
+	fields := strings.Fields(inner)
+
+	if len(fields) == 0 {
+		return "", "", false
+	}
+
+	return strings.ToLower(fields[0]), strings.TrimSpace(inner[len(fields[0]):]), true
+}
+
+func parseAssignment(args string) (string, string, error) {
+	fields := strings.Fields(args)
+
+	if len(fields) < 2 || !strings.HasPrefix(fields[0], "$") {
+		return "", "", fmt.Errorf("'<<set>>' expects '$variable to expression'")
+	}
+
+	name := fields[0][1:]
+	rest := strings.TrimSpace(strings.TrimPrefix(args, fields[0]))
+
+	if fields[1] == "to" {
+		rest = strings.TrimSpace(strings.TrimPrefix(rest, "to"))
+	} else if strings.HasPrefix(rest, "=") && !strings.HasPrefix(rest, "==") {
+		rest = strings.TrimSpace(rest[1:])
+	}
+
+	expr, err := convertExpr(rest)
+
+	if err != nil {
+		return "", "", err
+	}
+
+	return name, expr, nil
+}
+
+func splitSpeaker(text string) (string, string) {
+	sep := strings.Index(text, ":")
+
+	if sep <= 0 || sep > 32 || sep+1 >= len(text) || text[sep+1] != ' ' {
+		return "", text
+	}
+
+	return strings.TrimSpace(text[:sep]), strings.TrimSpace(text[sep+1:])
+}
+
+func splitTags(text string) (string, map[string]string) {
+	tags := map[string]string{}
+	fields := strings.Fields(text)
+	idx := len(fields)
+
+	for idx > 0 && strings.HasPrefix(fields[idx-1], "#") {
+		idx--
+		tag := strings.SplitN(fields[idx][1:], ":", 2)
+
+		if len(tag) == 2 {
+			tags[tag[0]] = tag[1]
+		} else {
+			tags[tag[0]] = ""
+		}
+	}
+
+	return strings.Join(fields[:idx], " "), tags
+}
+
+func (st *statement) applyTags(tags map[string]string) error {
+	for k, v := range tags {
+		if k != "timeout" && k != "auto" && k != "default" {
+			continue
+		}
+
+		val, err := strconv.ParseFloat(v, 32)
+
+		if err != nil {
+			return fmt.Errorf("tag '#%s' expects a number, got: '%s'", k, v)
+		}
+
+		switch k {
+		case "timeout":
+			st.timeout = float32(val)
+		case "auto":
+			st.autoAdvance = float32(val)
+		case "default":
+			st.defaultChoice = int(val)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,206 @@
+// Package yarn converts Yarn Spinner-like scripts into dialogue trees
+// understood by the game's dialogue system.
+package yarn
+
+import (
+	"fmt"
+)
+
+const (
+	// StartNode is the node dialogues begin with when present
+	StartNode = "Start"
+)
+
+type compiler struct {
+	title   string
+	counter int
+	nodes   []*Dialogue
+	jumps   map[string]int
+}
+
+// Import parses a script and compiles it into a dialogue tree,
+// file is only used to report errors
+func Import(file string, data []byte) (*Dialogue, error) {
+	p := &parser{
+		file:  file,
+		lines: splitLines(data),
+	}
+
+	nodes, err := p.parseNodes()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("%s: script contains no nodes", file)
+	}
+
+	c := &compiler{
+		jumps: map[string]int{},
+	}
+
+	root := &Dialogue{Jump: nodes[0].title}
+	heads := []*Dialogue{}
+	titles := map[string]bool{}
+
+	for _, n := range nodes {
+		if titles[n.title] {
+			return nil, fmt.Errorf("%s:%d: node '%s' is defined twice", file, n.line, n.title)
+		}
+
+		titles[n.title] = true
+
+		if n.title == StartNode {
+			root.Jump = StartNode
+		}
+
+		c.title = n.title
+		c.counter = 0
+		head := c.block(n.body, nil)
+
+		if head == nil || head.ID != "" {
+			head = &Dialogue{Silent: true, Next: head}
+		}
+
+		head.ID = n.title
+		heads = append(heads, head)
+	}
+
+	for target, line := range c.jumps {
+		if !titles[target] {
+			return nil, fmt.Errorf("%s:%d: jump to unknown node '%s'", file, line, target)
+		}
+	}
+
+	root.Nodes = append(heads, c.nodes...)
+	return root, nil
+}
+
+func (c *compiler) block(stmts []statement, cont *Dialogue) *Dialogue {
+	if len(stmts) == 0 {
+		return cont
+	}
+
+	st := stmts[0]
+	rest := stmts[1:]
+
+	switch st.kind {
+	case stLine:
+		d := &Dialogue{
+			Name:          st.speaker,
+			Text:          st.text,
+			Timeout:       st.timeout,
+			DefaultChoice: st.defaultChoice,
+			AutoAdvance:   st.autoAdvance,
+		}
+
+		if len(rest) > 0 && rest[0].kind == stOptions {
+			d.Choices = c.choices(rest[0], c.block(rest[1:], cont))
+			return d
+		}
+
+		d.Next = c.block(rest, cont)
+		return d
+
+	case stOptions:
+		return &Dialogue{
+			Choices: c.choices(st, c.block(rest, cont)),
+		}
+
+	case stSet:
+		return &Dialogue{
+			Silent: true,
+			Set:    []string{fmt.Sprintf("%s = %s", st.name, st.expr)},
+			Next:   c.block(rest, cont),
+		}
+
+	case stEvent:
+		return &Dialogue{
+			Silent:    true,
+			Event:     st.name,
+			EventArgs: st.text,
+			Next:      c.block(rest, cont),
+		}
+
+	case stJump:
+		c.jumps[st.target] = st.line
+		return &Dialogue{Jump: st.target}
+
+	case stStop:
+		return nil
+
+	case stIf:
+		ref := c.share(c.block(rest, cont))
+		next := ref()
+
+		for idx := len(st.branches) - 1; idx >= 0; idx-- {
+			br := st.branches[idx]
+			body := c.block(br.body, ref())
+
+			if br.cond == "" {
+				next = body
+				continue
+			}
+
+			next = &Dialogue{
+				Silent: true,
+				If:     br.cond,
+				Next:   body,
+				Else:   next,
+			}
+		}
+
+		return next
+	}
+
+	return cont
+}
+
+func (c *compiler) choices(st statement, cont *Dialogue) []*Choice {
+	ref := c.share(cont)
+	res := []*Choice{}
+
+	for _, opt := range st.options {
+		ch := &Choice{
+			Text: opt.text,
+			If:   opt.cond,
+		}
+
+		if opt.target != "" {
+			c.jumps[opt.target] = st.line
+			ch.Next = &Dialogue{Jump: opt.target}
+		} else {
+			ch.Next = c.block(opt.body, ref())
+		}
+
+		res = append(res, ch)
+	}
+
+	return res
+}
+
+// share turns a continuation reachable from several branches into a named node,
+// so that it is not duplicated in the output
+func (c *compiler) share(cont *Dialogue) func() *Dialogue {
+	if cont == nil {
+		return func() *Dialogue { return nil }
+	}
+
+	if cont.ID == "" {
+		if cont.Jump != "" && isJumpOnly(cont) {
+			return func() *Dialogue { return &Dialogue{Jump: cont.Jump} }
+		}
+
+		c.counter++
+		cont.ID = fmt.Sprintf("%s#%d", c.title, c.counter)
+		c.nodes = append(c.nodes, cont)
+	}
+
+	return func() *Dialogue { return &Dialogue{Jump: cont.ID} }
+}
+
+func isJumpOnly(d *Dialogue) bool {
+	return d.Name == "" && d.Text == "" && d.Event == "" && d.If == "" &&
+		len(d.Set) == 0 && len(d.Choices) == 0 && d.Next == nil
+}
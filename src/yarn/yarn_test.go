@@ -0,0 +1,110 @@
+package yarn
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files with the current output")
+
+func TestImportGolden(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "*.yarn"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(files) == 0 {
+		t.Fatal("no scripts found")
+	}
+
+	for _, path := range files {
+		name := strings.TrimSuffix(filepath.Base(path), ".yarn")
+
+		t.Run(name, func(t *testing.T) {
+			data, err := ioutil.ReadFile(path)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			dia, err := Import(path, data)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			res, err := yaml.Marshal(dia)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			golden := filepath.Join("testdata", name+".golden")
+
+			if *update {
+				if err := ioutil.WriteFile(golden, res, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			want, err := ioutil.ReadFile(golden)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(res, want) {
+				t.Errorf("output differs from %s, run 'go test -update' after checking the change:\n%s", golden, res)
+			}
+		})
+	}
+}
+
+func TestImportErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"empty script", "", "test.yarn: script contains no nodes"},
+		{"header without separator", "title Start\n---\n===\n", "test.yarn:1: invalid header line 'title Start'"},
+		{"unterminated header", "title: Start\n", "test.yarn:1: node header is not terminated by '---'"},
+		{"missing title", "tags: a\n---\n===\n", "test.yarn:1: node has no title"},
+		{"unterminated body", "title: Start\n---\nHi.\n", "test.yarn:1: node 'Start' is not terminated by '==='"},
+		{"duplicate node", "title: A\n---\n===\ntitle: A\n---\n===\n", "test.yarn:4: node 'A' is defined twice"},
+		{"unknown jump", "title: A\n---\n<<jump B>>\n===\n", "test.yarn:3: jump to unknown node 'B'"},
+		{"unknown link", "title: A\n---\n[[Go|B]]\n===\n", "test.yarn:3: jump to unknown node 'B'"},
+		{"unsupported command", "title: A\n---\n<<wait 2>>\n===\n", "test.yarn:3: unsupported command '<<wait>>'"},
+		{"stray endif", "title: A\n---\n<<endif>>\n===\n", "test.yarn:3: unexpected '<<endif>>'"},
+		{"missing endif", "title: A\n---\n<<if $a>>\nHi.\n===\n", "test.yarn:4: missing '<<endif>>'"},
+		{"if without condition", "title: A\n---\n<<if>>\n<<endif>>\n===\n", "test.yarn:3: '<<if>>' needs a condition"},
+		{"duplicate else", "title: A\n---\n<<if $a>>\n<<else>>\n<<else>>\n<<endif>>\n===\n", "test.yarn:5: duplicate '<<else>>'"},
+		{"elseif after else", "title: A\n---\n<<if $a>>\n<<else>>\n<<elseif $b>>\n<<endif>>\n===\n", "test.yarn:5: '<<elseif>>' after '<<else>>'"},
+		{"set without variable", "title: A\n---\n<<set a to 1>>\n===\n", "test.yarn:3: '<<set>>' expects '$variable to expression'"},
+		{"event without name", "title: A\n---\n<<event>>\n===\n", "test.yarn:3: '<<event>>' needs an event name"},
+		{"option with a command", "title: A\n---\n-> Hi <<set $a to 1>>\n===\n", "test.yarn:3: options only accept an '<<if>>' condition"},
+		{"bad tag", "title: A\n---\nHi. #timeout:soon\n===\n", "test.yarn:3: tag '#timeout' expects a number, got: 'soon'"},
+		{"unterminated link", "title: A\n---\n[[B\n===\n", "test.yarn:3: unterminated link '[[B'"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Import("test.yarn", []byte(tc.src))
+
+			if err == nil {
+				t.Fatalf("got no error, want '%s'", tc.want)
+			}
+
+			if err.Error() != tc.want {
+				t.Errorf("got error '%s', want '%s'", err, tc.want)
+			}
+		})
+	}
+}